@@ -0,0 +1,87 @@
+// Command emf-agent-local is a local stand-in for the CloudWatch agent's EMF endpoint.
+//
+// It receives EMF events over TCP and UDP, validates them, aggregates the resulting
+// metrics per metric and dimension combination the way CloudWatch does, and serves
+// the results over an HTTP query API. A summary of all series is printed on exit.
+//
+// Usage:
+//
+//	emf-agent-local [-tcp addr] [-udp addr] [-http addr] [-v]
+//
+// Query the results with:
+//
+//	curl 'http://127.0.0.1:25889/series?namespace=MyApp&metric=Latency&dimension=Service:API'
+//	curl 'http://127.0.0.1:25889/stats'
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/localagent"
+)
+
+func main() {
+	flags := flag.NewFlagSet("emf-agent-local", flag.ExitOnError)
+	tcpAddr := flags.String("tcp", localagent.DefaultAddr, "TCP address to receive events on (empty to disable)")
+	udpAddr := flags.String("udp", localagent.DefaultAddr, "UDP address to receive events on (empty to disable)")
+	httpAddr := flags.String("http", "127.0.0.1:25889", "HTTP address of the query API (empty to disable)")
+	verbose := flags.Bool("v", false, "log every received event")
+	flags.Parse(os.Args[1:])
+
+	agent := localagent.New(localagent.Config{
+		TCPAddr:  *tcpAddr,
+		UDPAddr:  *udpAddr,
+		HTTPAddr: *httpAddr,
+		OnEvent:  eventLogger(os.Stderr, *verbose),
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "emf-agent-local: %v\n", err)
+		},
+	})
+	if err := agent.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "emf-agent-local: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, listener := range []struct {
+		name string
+		addr fmt.Stringer
+	}{
+		{"TCP", agent.TCPAddr()},
+		{"UDP", agent.UDPAddr()},
+		{"HTTP", agent.HTTPAddr()},
+	} {
+		if listener.addr != nil {
+			fmt.Fprintf(os.Stderr, "emf-agent-local: listening for %s on %s\n", listener.name, listener.addr)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	if err := agent.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "emf-agent-local: %v\n", err)
+	}
+	if err := agent.Store().WriteSummary(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "emf-agent-local: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// eventLogger returns an OnEvent callback that reports rejected events, and
+// accepted events as well if verbose is set.
+func eventLogger(w io.Writer, verbose bool) func(data []byte, err error) {
+	return func(data []byte, err error) {
+		switch {
+		case err != nil:
+			fmt.Fprintf(w, "rejected: %v: %s\n", err, data)
+		case verbose:
+			fmt.Fprintf(w, "accepted: %s\n", data)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Command emf-catalog generates typed Go helpers from a metric catalog.
+//
+// The catalog is a YAML or JSON file declaring a namespace, its metrics with
+// their units, and the allowed dimensions and dimension sets; see package
+// emfcatalog. The generated file declares constants for every name and a Metrics
+// type with one method per dimension and metric. It is meant to be run by go
+// generate:
+//
+//	//go:generate emf-catalog -o metrics_gen.go metrics.yaml
+//
+// Usage:
+//
+//	emf-catalog [-package name] [-o file] catalog
+//
+// The package defaults to $GOPACKAGE, as set by go generate, and the output to
+// standard output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/emfcatalog"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns its exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("emf-catalog", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	pkg := flags.String("package", os.Getenv("GOPACKAGE"), "package of the generated file")
+	output := flags.String("o", "", "output file (default standard output)")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: emf-catalog [-package name] [-o file] catalog")
+		return exitError
+	}
+	if *pkg == "" {
+		fmt.Fprintln(stderr, "emf-catalog: -package is required outside of go generate")
+		return exitError
+	}
+
+	catalog, err := emfcatalog.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-catalog: %s: %v\n", flags.Arg(0), err)
+		return exitError
+	}
+	source, err := emfcatalog.Generate(catalog, *pkg)
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-catalog: %v\n", err)
+		return exitError
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(source)
+	} else {
+		err = os.WriteFile(*output, source, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-catalog: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
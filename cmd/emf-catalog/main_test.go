@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	catalog := filepath.Join(dir, "metrics.yaml")
+	if err := os.WriteFile(catalog, []byte("namespace: Checkout\ndimensions: [ServiceName]\nmetrics: [{name: Latency, unit: Milliseconds}]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-package", "checkout", catalog}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "func (m *Metrics) PutLatency(") {
+		t.Errorf("Expected the generated helpers, got:\n%s", stdout.String())
+	}
+
+	output := filepath.Join(dir, "metrics_gen.go")
+	stdout.Reset()
+	if code := run([]string{"-package", "checkout", "-o", output, catalog}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+	if data, err := os.ReadFile(output); err != nil || !strings.Contains(string(data), "package checkout") {
+		t.Errorf("Expected the generated file to be written, got %v", err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-package", "checkout"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d without a catalog, got %d", exitError, code)
+	}
+	if code := run([]string{"-package", "checkout", "missing.yaml"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d for a missing catalog, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "missing.yaml") {
+		t.Errorf("Expected the catalog to be named in the error, got: %s", stderr.String())
+	}
+}
@@ -0,0 +1,426 @@
+// Command emf-convert flattens CloudWatch Embedded Metric Format events into
+// formats that are easy to graph locally.
+//
+// It reads EMF events line by line from the named files, or from standard input,
+// and writes one row per metric value and dimension set as CSV or JSON Lines, or
+// the value of every metric series in its latest event in the Prometheus text
+// exposition format.
+//
+// Usage:
+//
+//	emf-convert [-format csv|jsonl|prometheus] [file ...]
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zlatkoc/go-aws-emf/internal/emfio"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 2
+)
+
+// row is a single flattened metric value.
+type row struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Namespace  string            `json:"namespace"`
+	Metric     string            `json:"metric"`
+	Unit       string            `json:"unit"`
+	Value      float64           `json:"value"`
+	Count      float64           `json:"count"`
+	Dimensions map[string]string `json:"dimensions"`
+
+	// event is the position of the event the row was flattened from.
+	event int
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("emf-convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "csv", "output format: csv, jsonl or prometheus")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	var write func([]row, io.Writer) error
+	switch *format {
+	case "csv":
+		write = writeCSV
+	case "jsonl":
+		write = writeJSONL
+	case "prometheus":
+		write = writePrometheus
+	default:
+		fmt.Fprintf(stderr, "emf-convert: unknown format %q\n", *format)
+		return exitError
+	}
+
+	var rows []row
+	var events int
+	err := emfio.ScanFiles(flags.Args(), stdin, func(record emfio.Record) error {
+		if record.Data == nil {
+			return nil
+		}
+
+		ml, err := emf.ParseMetricLog(record.Data)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s:%d: skipping event: %v\n", record.Source, record.Line, err)
+			return nil
+		}
+		rows = append(rows, flatten(ml, events)...)
+		events++
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-convert: %v\n", err)
+		return exitError
+	}
+
+	if err := write(rows, stdout); err != nil {
+		fmt.Fprintf(stderr, "emf-convert: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// flatten returns one row per metric value and dimension set of every metric
+// directive in the log, which is the event at the given position. Each dimension
+// set is a separate series, as in CloudWatch; a directive without dimension sets
+// yields rows without dimensions.
+func flatten(ml *emf.MetricLog, event int) []row {
+	var rows []row
+	for _, directive := range ml.Metadata().CloudWatchMetrics {
+		dimSets := directive.Dimensions
+		if len(dimSets) == 0 {
+			dimSets = [][]string{nil}
+		}
+
+		for _, dimSet := range dimSets {
+			dimensions := make(map[string]string, len(dimSet))
+			for _, dim := range dimSet {
+				if value, exists := ml.Value(dim); exists {
+					dimensions[dim] = fmt.Sprint(value)
+				}
+			}
+
+			for _, metric := range directive.Metrics {
+				unit := emf.UnitNone
+				if metric.Unit != nil {
+					unit = *metric.Unit
+				}
+
+				value, _ := ml.Value(metric.Name)
+				for _, s := range samples(value) {
+					rows = append(rows, row{
+						Timestamp:  ml.Timestamp().UTC(),
+						Namespace:  directive.Namespace,
+						Metric:     metric.Name,
+						Unit:       unit,
+						Value:      s.value,
+						Count:      s.count,
+						Dimensions: dimensions,
+						event:      event,
+					})
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// sample is a metric value together with the number of times it was observed.
+type sample struct {
+	value float64
+	count float64
+}
+
+// samples decodes a parsed metric value. Plain numbers and arrays yield a sample per
+// value; distributions yield a sample per value with its count; statistic sets
+// without values yield their average with the sample count.
+func samples(value interface{}) []sample {
+	switch v := value.(type) {
+	case float64:
+		return []sample{{v, 1}}
+	case []interface{}:
+		var result []sample
+		for _, item := range v {
+			if f, ok := item.(float64); ok {
+				result = append(result, sample{f, 1})
+			}
+		}
+		return result
+	case map[string]interface{}:
+		values, _ := v["Values"].([]interface{})
+		counts, _ := v["Counts"].([]interface{})
+		if len(values) > 0 {
+			var result []sample
+			for i, item := range values {
+				f, ok := item.(float64)
+				if !ok {
+					continue
+				}
+				count := 1.0
+				if i < len(counts) {
+					if c, ok := counts[i].(float64); ok {
+						count = c
+					}
+				}
+				result = append(result, sample{f, count})
+			}
+			return result
+		}
+
+		sum, sumOK := v["Sum"].(float64)
+		count, countOK := v["Count"].(float64)
+		if !countOK {
+			count, countOK = v["SampleCount"].(float64)
+		}
+		if sumOK && countOK && count > 0 {
+			return []sample{{sum / count, count}}
+		}
+	}
+	return nil
+}
+
+// dimensionKeys returns the sorted union of the dimension keys of all rows.
+func dimensionKeys(rows []row) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, r := range rows {
+		for key := range r.Dimensions {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeCSV writes the rows as CSV with one column per dimension key.
+func writeCSV(rows []row, w io.Writer) error {
+	keys := dimensionKeys(rows)
+
+	writer := csv.NewWriter(w)
+	header := append([]string{"timestamp", "namespace", "metric", "unit", "value", "count"}, keys...)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		record := []string{
+			r.Timestamp.Format(time.RFC3339Nano),
+			r.Namespace,
+			r.Metric,
+			r.Unit,
+			strconv.FormatFloat(r.Value, 'g', -1, 64),
+			strconv.FormatFloat(r.Count, 'g', -1, 64),
+		}
+		for _, key := range keys {
+			record = append(record, r.Dimensions[key])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeJSONL writes the rows as JSON Lines.
+func writeJSONL(rows []row, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePrometheus writes every metric series in the Prometheus text exposition
+// format, using the latest event of the series. Metrics with a single value per event
+// are written as gauges. Metrics with several values per event, such as arrays,
+// distributions and statistic sets, are written as summaries with a sum and a count.
+// The namespace and the dimensions become labels. Names that are empty or collide
+// after replacing invalid characters are prefixed, see uniqueName.
+func writePrometheus(rows []row, w io.Writer) error {
+	type seriesValue struct {
+		labels    string
+		timestamp time.Time
+		event     int
+		sum       float64
+		count     float64
+		samples   int
+	}
+
+	metricNames := prometheusMetricNames(rows)
+	latest := make(map[string]map[string]*seriesValue)
+	units := make(map[string]string)
+	for _, r := range rows {
+		name := metricNames[r.Metric]
+		units[name] = r.Unit
+
+		labels := formatLabels(r.Namespace, r.Dimensions)
+		series, exists := latest[name]
+		if !exists {
+			series = make(map[string]*seriesValue)
+			latest[name] = series
+		}
+
+		current, exists := series[labels]
+		switch {
+		case exists && current.event == r.event:
+			// Another value of the same event
+		case !exists || !r.Timestamp.Before(current.timestamp):
+			current = &seriesValue{labels: labels, timestamp: r.Timestamp, event: r.event}
+			series[labels] = current
+		default:
+			continue
+		}
+		current.sum += r.Value * r.Count
+		current.count += r.Count
+		current.samples++
+	}
+
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		series := make([]*seriesValue, 0, len(latest[name]))
+		summary := false
+		for _, s := range latest[name] {
+			series = append(series, s)
+			summary = summary || s.samples > 1 || s.count != 1
+		}
+		sort.Slice(series, func(i, j int) bool { return series[i].labels < series[j].labels })
+
+		metricType := "gauge"
+		if summary {
+			metricType = "summary"
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s EMF metric in %s.\n# TYPE %s %s\n", name, units[name], name, metricType); err != nil {
+			return err
+		}
+
+		for _, s := range series {
+			var err error
+			if summary {
+				_, err = fmt.Fprintf(w, "%s_sum{%s} %s %d\n%s_count{%s} %s %d\n",
+					name, s.labels, strconv.FormatFloat(s.sum, 'g', -1, 64), s.timestamp.UnixMilli(),
+					name, s.labels, strconv.FormatFloat(s.count, 'g', -1, 64), s.timestamp.UnixMilli())
+			} else {
+				_, err = fmt.Fprintf(w, "%s{%s} %s %d\n", name, s.labels,
+					strconv.FormatFloat(s.sum, 'g', -1, 64), s.timestamp.UnixMilli())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatLabels renders the namespace and dimensions as a sorted Prometheus label set.
+// Dimensions whose names collide with the namespace label or with each other are
+// prefixed with exported_, as Prometheus does for conflicting labels.
+func formatLabels(namespace string, dimensions map[string]string) string {
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := []string{fmt.Sprintf(`namespace="%s"`, escapeLabelValue(namespace))}
+	taken := map[string]bool{"namespace": true}
+	for _, key := range keys {
+		name := uniqueName(key, "exported_", taken)
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(dimensions[key])))
+	}
+	sort.Strings(labels[1:])
+	return strings.Join(labels, ",")
+}
+
+// prometheusMetricNames maps the metric names of the rows to unique Prometheus
+// metric names. The names are assigned in sorted order, so that the same metrics
+// always get the same names.
+func prometheusMetricNames(rows []row) map[string]string {
+	names := make(map[string]string)
+	var metrics []string
+	for _, r := range rows {
+		if _, exists := names[r.Metric]; !exists {
+			names[r.Metric] = ""
+			metrics = append(metrics, r.Metric)
+		}
+	}
+	sort.Strings(metrics)
+
+	taken := make(map[string]bool)
+	for _, metric := range metrics {
+		names[metric] = uniqueName(metric, "emf_", taken)
+	}
+	return names
+}
+
+// uniqueName returns a Prometheus name for name that is not in taken, and adds it
+// to taken. A name that is empty or taken after replacing invalid characters is
+// prefixed, and gets a numeric suffix if the prefixed name is taken as well.
+func uniqueName(name, prefix string, taken map[string]bool) string {
+	candidate := prometheusName(name)
+	if candidate == "" || taken[candidate] {
+		base := prometheusName(prefix + name)
+		candidate = base
+		for i := 2; taken[candidate]; i++ {
+			candidate = base + "_" + strconv.Itoa(i)
+		}
+	}
+	taken[candidate] = true
+	return candidate
+}
+
+// prometheusName replaces characters that are not valid in Prometheus metric and label names.
+func prometheusName(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// escapeLabelValue escapes a Prometheus label value.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
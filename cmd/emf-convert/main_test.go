@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const input = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"},{"Name":"Requests","Unit":"Count"}]}]},"Service":"API","Latency":[10,20],"Requests":1}
+2020-09-13T12:26:41.000Z {"_aws":{"Timestamp":1600000060000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service","Region"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]},"Service":"API","Region":"eu-west-1","Latency":{"Values":[5,15],"Counts":[3,1],"Max":15,"Min":5,"Count":4,"Sum":30}}
+not an EMF line
+`
+
+func TestRunCSV(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, strings.NewReader(input), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	expected := strings.Join([]string{
+		"timestamp,namespace,metric,unit,value,count,Region,Service",
+		"2020-09-13T12:26:40Z,Test,Latency,Milliseconds,10,1,,API",
+		"2020-09-13T12:26:40Z,Test,Latency,Milliseconds,20,1,,API",
+		"2020-09-13T12:26:40Z,Test,Requests,Count,1,1,,API",
+		"2020-09-13T12:27:40Z,Test,Latency,Milliseconds,5,3,eu-west-1,API",
+		"2020-09-13T12:27:40Z,Test,Latency,Milliseconds,15,1,eu-west-1,API",
+	}, "\n") + "\n"
+
+	if stdout.String() != expected {
+		t.Errorf("Unexpected CSV output:\n%s\nexpected:\n%s", stdout.String(), expected)
+	}
+}
+
+func TestRunJSONL(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-format", "jsonl"}, strings.NewReader(input), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 rows, got %d", len(lines))
+	}
+
+	var first row
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Expected JSON output, got error: %v", err)
+	}
+	if first.Metric != "Latency" || first.Value != 10 || first.Dimensions["Service"] != "API" {
+		t.Errorf("Unexpected first row: %+v", first)
+	}
+}
+
+func TestRunPrometheus(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-format", "prometheus"}, strings.NewReader(input), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{
+		"# TYPE Latency summary\n",
+		`Latency_sum{namespace="Test",Service="API"} 30 1600000000000` + "\n",
+		`Latency_count{namespace="Test",Service="API"} 2 1600000000000` + "\n",
+		`Latency_sum{namespace="Test",Region="eu-west-1",Service="API"} 30 1600000060000` + "\n",
+		`Latency_count{namespace="Test",Region="eu-west-1",Service="API"} 4 1600000060000` + "\n",
+		"# TYPE Requests gauge\n",
+		`Requests{namespace="Test",Service="API"} 1 1600000000000` + "\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestRunPrometheusLatestEvent(t *testing.T) {
+	const events = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["namespace"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]},"namespace":"orders","Latency":[50,90]}
+{"_aws":{"Timestamp":1600000060000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["namespace"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]},"namespace":"orders","Latency":[30,10]}
+`
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-format", "prometheus"}, strings.NewReader(events), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{
+		`Latency_sum{namespace="Test",exported_namespace="orders"} 40 1600000060000` + "\n",
+		`Latency_count{namespace="Test",exported_namespace="orders"} 2 1600000060000` + "\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestRunSkipsInvalidEvents(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-format", "jsonl"}, strings.NewReader(`{"_aws": {`+"\n"), &stdout, &stderr)
+
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(stderr.String(), "-:1: skipping event") {
+		t.Errorf("Expected a warning for the invalid event, got %q", stderr.String())
+	}
+}
+
+func TestRunDimensionSets(t *testing.T) {
+	const event = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"],["Service","Region"]],"Metrics":[{"Name":"Requests","Unit":"Count"}]}]},"Service":"API","Region":"eu-west-1","Requests":1}
+`
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, strings.NewReader(event), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	expected := strings.Join([]string{
+		"timestamp,namespace,metric,unit,value,count,Region,Service",
+		"2020-09-13T12:26:40Z,Test,Requests,Count,1,1,,API",
+		"2020-09-13T12:26:40Z,Test,Requests,Count,1,1,eu-west-1,API",
+	}, "\n") + "\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected a row per dimension set:\n%s\nexpected:\n%s", stdout.String(), expected)
+	}
+}
+
+func TestRunPrometheusNameCollisions(t *testing.T) {
+	const event = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["a.b","a_b"]],"Metrics":[{"Name":"Request.Count"},{"Name":"Request_Count"},{"Name":"!"}]}]},"a.b":"x","a_b":"y","Request.Count":1,"Request_Count":2,"!":3}
+`
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-format", "prometheus"}, strings.NewReader(event), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	output := stdout.String()
+	labels := `{namespace="Test",a_b="x",exported_a_b="y"}`
+	for _, expected := range []string{
+		"_" + labels + " 3 1600000000000\n",
+		"Request_Count" + labels + " 1 1600000000000\n",
+		"emf_Request_Count" + labels + " 2 1600000000000\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	taken := make(map[string]bool)
+	for _, tt := range []struct {
+		name     string
+		expected string
+	}{
+		{"a.b", "a_b"},
+		{"a-b", "emf_a_b"},
+		{"a b", "emf_a_b_2"},
+		{"", "emf_"},
+	} {
+		if actual := uniqueName(tt.name, "emf_", taken); actual != tt.expected {
+			t.Errorf("Expected uniqueName(%q) to be %q, got %q", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	tests := map[string]string{
+		"Latency":       "Latency",
+		"Requests/Sec":  "Requests_Sec",
+		"5xxErrors":     "_5xxErrors",
+		"api.latency-2": "api_latency_2",
+	}
+
+	for input, expected := range tests {
+		if actual := prometheusName(input); actual != expected {
+			t.Errorf("Expected prometheusName(%q) to be %q, got %q", input, expected, actual)
+		}
+	}
+}
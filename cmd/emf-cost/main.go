@@ -0,0 +1,125 @@
+// Command emf-cost estimates how many CloudWatch custom metrics a stream of
+// Embedded Metric Format events creates, and what they cost per month.
+//
+// It reads EMF events line by line from the named files, or from standard input,
+// counts the unique metric series per namespace using CloudWatch's extraction
+// rules, and reports the dimensions that contribute the most series.
+//
+// Usage:
+//
+//	emf-cost [-format text|json] [-prices prices.json] [-top n] [file ...]
+//
+// The price table is a JSON array of tiers, for example:
+//
+//	[{"upTo": 10000, "pricePerMetric": 0.30}, {"upTo": 0, "pricePerMetric": 0.10}]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zlatkoc/go-aws-emf/internal/emfio"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("emf-cost", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text or json")
+	pricesFile := flags.String("prices", "", "JSON file with the price table (default: CloudWatch us-east-1 prices)")
+	top := flags.Int("top", emf.DefaultTopDimensions, "number of top contributing dimensions per namespace")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "emf-cost: unknown format %q\n", *format)
+		return exitError
+	}
+
+	var prices emf.PriceTable
+	if *pricesFile != "" {
+		var err error
+		if prices, err = readPrices(*pricesFile); err != nil {
+			fmt.Fprintf(stderr, "emf-cost: %v\n", err)
+			return exitError
+		}
+	}
+
+	estimator := emf.NewCostEstimator(emf.CostEstimatorConfig{
+		Prices:        prices,
+		TopDimensions: *top,
+	})
+
+	skipped := 0
+	err := emfio.ScanFiles(flags.Args(), stdin, func(record emfio.Record) error {
+		if record.Data == nil {
+			return nil
+		}
+		if err := estimator.AddJSON(record.Data); err != nil {
+			fmt.Fprintf(stderr, "%s:%d: skipping event: %v\n", record.Source, record.Line, err)
+			skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-cost: %v\n", err)
+		return exitError
+	}
+
+	estimate := estimator.Estimate()
+	if *format == "json" {
+		err = json.NewEncoder(stdout).Encode(estimate)
+	} else {
+		err = writeText(estimate, skipped, stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-cost: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// readPrices reads a price table from a JSON file.
+func readPrices(path string) (emf.PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices emf.PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("invalid price table %s: %w", path, err)
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("invalid price table %s: no tiers", path)
+	}
+	return prices, nil
+}
+
+// writeText writes a human-readable report of the estimate.
+func writeText(estimate emf.CostEstimate, skipped int, w io.Writer) error {
+	fmt.Fprintf(w, "Events: %d (%d skipped)\n", estimate.Events, skipped)
+	for _, ns := range estimate.Namespaces {
+		fmt.Fprintf(w, "\nNamespace %s: %d series, %d metrics, $%.2f/month\n", ns.Namespace, ns.Series, ns.Metrics, ns.MonthlyCost)
+		for _, dim := range ns.TopDimensions {
+			fmt.Fprintf(w, "  %-24s %d series, %d distinct values\n", dim.Name, dim.Series, dim.DistinctValues)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\nTotal: %d custom metrics, estimated $%.2f/month\n", estimate.Series, estimate.MonthlyCost)
+	return err
+}
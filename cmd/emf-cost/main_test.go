@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+const input = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"],["Service","Host"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]},"Service":"API","Host":"a","Latency":10}
+{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"],["Service","Host"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]},"Service":"API","Host":"b","Latency":20}
+{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency"}]}]},"Latency":1}
+not an EMF line
+`
+
+func TestRunText(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, strings.NewReader(input), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{
+		"Events: 2 (1 skipped)",
+		"Namespace Test: 3 series, 1 metrics, $0.90/month",
+		"Host                     2 series, 2 distinct values",
+		"Total: 3 custom metrics, estimated $0.90/month",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if !strings.Contains(stderr.String(), "-:3: skipping event") {
+		t.Errorf("Expected the invalid event to be reported, got: %s", stderr.String())
+	}
+}
+
+func TestRunJSONWithPrices(t *testing.T) {
+	prices := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(prices, []byte(`[{"upTo": 2, "pricePerMetric": 1}, {"upTo": 0, "pricePerMetric": 0.5}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-format", "json", "-prices", prices, "-top", "1"}
+	if code := run(args, strings.NewReader(input), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+
+	var estimate emf.CostEstimate
+	if err := json.Unmarshal(stdout.Bytes(), &estimate); err != nil {
+		t.Fatalf("Expected JSON output, got error: %v", err)
+	}
+	if estimate.Series != 3 || estimate.MonthlyCost != 2.5 {
+		t.Errorf("Unexpected estimate: %+v", estimate)
+	}
+	if len(estimate.Namespaces) != 1 || len(estimate.Namespaces[0].TopDimensions) != 1 {
+		t.Errorf("Unexpected namespaces: %+v", estimate.Namespaces)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := [][]string{
+		{"-format", "xml"},
+		{"-prices", filepath.Join(t.TempDir(), "missing.json")},
+		{"missing.log"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, strings.NewReader(""), &stdout, &stderr); code != exitError {
+			t.Errorf("Expected exit code %d for %v, got %d", exitError, args, code)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Command emf-lint validates CloudWatch Embedded Metric Format events.
+//
+// It reads EMF events line by line from the named files, or from standard input,
+// and checks each event against the rules of MetricLog.Validate and the bundled
+// EMF JSON schema. Lines may be bare EMF documents or lines of a CloudWatch Logs
+// export. Lines without an EMF document are skipped unless -require-emf is set.
+//
+// Usage:
+//
+//	emf-lint [-format text|json] [-require-emf] [file ...]
+//
+// The exit code is 0 if every event is valid, 1 if any event is invalid and 2 on
+// usage or I/O errors.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zlatkoc/go-aws-emf/internal/emfio"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// Exit codes
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+// Diagnostic rules
+const (
+	ruleInput    = "input"
+	ruleSchema   = "schema"
+	ruleParse    = "parse"
+	ruleValidate = "validate"
+)
+
+// diagnostic is a single problem found in an input line.
+type diagnostic struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// result holds the diagnostics of one invalid input line.
+type result struct {
+	File   string       `json:"file"`
+	Line   int          `json:"line"`
+	Errors []diagnostic `json:"errors"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("emf-lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text or json")
+	requireEMF := flags.Bool("require-emf", false, "report lines that do not contain an EMF event")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "emf-lint: unknown format %q\n", *format)
+		return exitError
+	}
+
+	encoder := json.NewEncoder(stdout)
+	checked, invalid := 0, 0
+
+	err := emfio.ScanFiles(flags.Args(), stdin, func(record emfio.Record) error {
+		var diagnostics []diagnostic
+		if record.Data == nil {
+			if !*requireEMF {
+				return nil
+			}
+			diagnostics = append(diagnostics, diagnostic{Rule: ruleInput, Message: "line does not contain an EMF event"})
+		} else {
+			checked++
+			diagnostics = lint(record.Data)
+		}
+
+		if len(diagnostics) == 0 {
+			return nil
+		}
+		invalid++
+
+		if *format == "json" {
+			return encoder.Encode(result{File: record.Source, Line: record.Line, Errors: diagnostics})
+		}
+		for _, d := range diagnostics {
+			if _, err := fmt.Fprintf(stdout, "%s:%d: [%s] %s\n", record.Source, record.Line, d.Rule, d.Message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "emf-lint: %v\n", err)
+		return exitError
+	}
+
+	if *format == "text" {
+		fmt.Fprintf(stderr, "%d EMF events checked, %d invalid lines\n", checked, invalid)
+	}
+
+	if invalid > 0 {
+		return exitInvalid
+	}
+	return exitOK
+}
+
+// lint checks a single EMF document against the schema and the library's validation rules.
+func lint(data []byte) []diagnostic {
+	var diagnostics []diagnostic
+
+	if err := emf.ValidateJSON(data); err != nil {
+		var schemaErr *emf.SchemaError
+		if !errors.As(err, &schemaErr) {
+			return append(diagnostics, diagnostic{Rule: ruleParse, Message: err.Error()})
+		}
+		for _, violation := range schemaErr.Violations {
+			diagnostics = append(diagnostics, diagnostic{Rule: ruleSchema, Message: violation.String()})
+		}
+	}
+
+	ml, err := emf.ParseMetricLog(data)
+	if err != nil {
+		return append(diagnostics, diagnostic{Rule: ruleParse, Message: err.Error()})
+	}
+	if err := ml.Validate(); err != nil {
+		diagnostics = append(diagnostics, diagnostic{Rule: ruleValidate, Message: err.Error()})
+	}
+
+	return diagnostics
+}
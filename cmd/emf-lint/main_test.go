@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const (
+	validEvent   = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]},"Service":"API","Latency":42}`
+	invalidEvent = `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency"}]}]},"Latency":42}`
+)
+
+func TestRunValidInput(t *testing.T) {
+	input := strings.Join([]string{
+		validEvent,
+		"2024-01-01T00:00:00.000Z " + validEvent,
+		"not an EMF line",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run(nil, strings.NewReader(input), &stdout, &stderr)
+
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d (output: %s)", exitOK, code, stdout.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no diagnostics, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "2 EMF events checked") {
+		t.Errorf("Expected a summary, got %q", stderr.String())
+	}
+}
+
+func TestRunInvalidInputText(t *testing.T) {
+	input := validEvent + "\n" + invalidEvent + "\n"
+
+	var stdout, stderr bytes.Buffer
+	code := run(nil, strings.NewReader(input), &stdout, &stderr)
+
+	if code != exitInvalid {
+		t.Fatalf("Expected exit code %d, got %d", exitInvalid, code)
+	}
+	if !strings.Contains(stdout.String(), "-:2: [validate] dimension 'Service' is referenced but no value is provided") {
+		t.Errorf("Expected a validation diagnostic for line 2, got %q", stdout.String())
+	}
+}
+
+func TestRunInvalidInputJSON(t *testing.T) {
+	input := invalidEvent + "\n" + `{"_aws": {` + "\n"
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-format", "json"}, strings.NewReader(input), &stdout, &stderr)
+
+	if code != exitInvalid {
+		t.Fatalf("Expected exit code %d, got %d", exitInvalid, code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 results, got %d: %q", len(lines), stdout.String())
+	}
+
+	var first, second result
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Expected JSON output, got error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Expected JSON output, got error: %v", err)
+	}
+
+	if first.Line != 1 || len(first.Errors) == 0 || first.Errors[0].Rule != ruleValidate {
+		t.Errorf("Unexpected first result: %+v", first)
+	}
+	if second.Line != 2 || len(second.Errors) == 0 || second.Errors[0].Rule != ruleParse {
+		t.Errorf("Unexpected second result: %+v", second)
+	}
+}
+
+func TestRunSchemaViolation(t *testing.T) {
+	input := `{"_aws":{"Timestamp":"now","CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency"}]}]},"Service":"API","Latency":42}`
+
+	var stdout, stderr bytes.Buffer
+	code := run(nil, strings.NewReader(input), &stdout, &stderr)
+
+	if code != exitInvalid {
+		t.Fatalf("Expected exit code %d, got %d", exitInvalid, code)
+	}
+	if !strings.Contains(stdout.String(), "[schema]") {
+		t.Errorf("Expected a schema diagnostic, got %q", stdout.String())
+	}
+}
+
+func TestRunRequireEMF(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-require-emf"}, strings.NewReader("not an EMF line\n"), &stdout, &stderr)
+
+	if code != exitInvalid {
+		t.Fatalf("Expected exit code %d, got %d", exitInvalid, code)
+	}
+	if !strings.Contains(stdout.String(), "[input]") {
+		t.Errorf("Expected an input diagnostic, got %q", stdout.String())
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-format", "xml"}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d for an unknown format, got %d", exitError, code)
+	}
+	if code := run([]string{"does-not-exist.log"}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d for a missing file, got %d", exitError, code)
+	}
+}
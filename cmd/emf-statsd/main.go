@@ -0,0 +1,78 @@
+// Command emf-statsd receives StatsD and DogStatsD metrics over UDP and writes
+// them to standard output as CloudWatch Embedded Metric Format events.
+//
+// Metrics are aggregated per flush interval; DogStatsD tags become dimensions.
+// Remaining metrics are flushed on exit.
+//
+// Usage:
+//
+//	emf-statsd [-addr addr] [-namespace ns] [-interval d] [-dimension Name=Value ...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/emfstatsd"
+)
+
+// dimensionFlags collects repeated -dimension Name=Value flags.
+type dimensionFlags []emf.Dimension
+
+func (d *dimensionFlags) String() string {
+	pairs := make([]string, len(*d))
+	for i, dim := range *d {
+		pairs[i] = dim.Name + "=" + dim.Value
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (d *dimensionFlags) Set(value string) error {
+	name, dimValue, found := strings.Cut(value, "=")
+	if !found || name == "" || dimValue == "" {
+		return fmt.Errorf("expected Name=Value, got %q", value)
+	}
+	*d = append(*d, emf.Dimension{Name: name, Value: dimValue})
+	return nil
+}
+
+func main() {
+	var dimensions dimensionFlags
+
+	flags := flag.NewFlagSet("emf-statsd", flag.ExitOnError)
+	addr := flags.String("addr", emfstatsd.DefaultAddr, "UDP address to receive StatsD metrics on")
+	namespace := flags.String("namespace", emfstatsd.DefaultNamespace, "CloudWatch namespace of the metrics")
+	interval := flags.Duration("interval", emf.DefaultFlushInterval, "flush interval")
+	flags.Var(&dimensions, "dimension", "dimension added to every metric, as Name=Value (repeatable)")
+	flags.Parse(os.Args[1:])
+
+	receiver := emfstatsd.New(emfstatsd.Config{
+		Addr:              *addr,
+		Namespace:         *namespace,
+		Sink:              emf.NewWriterSink(os.Stdout),
+		Interval:          *interval,
+		DefaultDimensions: dimensions,
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "emf-statsd: %v\n", err)
+		},
+	})
+	if err := receiver.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "emf-statsd: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "emf-statsd: listening on %s\n", receiver.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	if err := receiver.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "emf-statsd: %v\n", err)
+		os.Exit(1)
+	}
+}
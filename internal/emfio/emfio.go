@@ -0,0 +1,125 @@
+// Package emfio reads EMF events from log files, standard input and CloudWatch Logs exports.
+package emfio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxLineSize is the largest line the scanner accepts. CloudWatch Logs events are
+// limited to 1 MB, so a line of an export never exceeds this by much.
+const maxLineSize = 2 * 1024 * 1024
+
+// Record is a single non-blank input line.
+type Record struct {
+	// Source is the name of the file the line was read from, or "-" for standard input.
+	Source string
+	// Line is the 1-based line number within Source.
+	Line int
+	// Text is the raw line.
+	Text []byte
+	// Data is the EMF document found in the line, or nil if the line contains none.
+	Data []byte
+}
+
+// Extract returns the EMF document contained in a log line. It accepts bare EMF
+// documents, lines with a prefix such as a timestamp (as in CloudWatch Logs exports
+// to S3 or Lambda output), and JSON log events that carry the document in a "message" field.
+func Extract(line []byte) ([]byte, bool) {
+	line = bytes.TrimSpace(line)
+
+	start := bytes.IndexByte(line, '{')
+	if start < 0 {
+		return nil, false
+	}
+	candidate := line[start:]
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(candidate, &fields); err != nil {
+		// Report malformed EMF documents rather than skipping them.
+		if bytes.Contains(candidate, []byte(`"_aws"`)) {
+			return candidate, true
+		}
+		return nil, false
+	}
+
+	if _, exists := fields["_aws"]; exists {
+		return candidate, true
+	}
+
+	if raw, exists := fields["message"]; exists {
+		var message string
+		if err := json.Unmarshal(raw, &message); err == nil {
+			return Extract([]byte(message))
+		}
+	}
+
+	return nil, false
+}
+
+// Scan reads r line by line and calls fn for every non-blank line.
+func Scan(r io.Reader, source string, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		record := Record{
+			Source: source,
+			Line:   lineNumber,
+			Text:   append([]byte(nil), text...),
+		}
+		if data, ok := Extract(record.Text); ok {
+			record.Data = data
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	return nil
+}
+
+// ScanFiles scans each of the named files in turn, or stdin if paths is empty.
+// A path of "-" also denotes stdin.
+func ScanFiles(paths []string, stdin io.Reader, fn func(Record) error) error {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	for _, path := range paths {
+		if path == "-" {
+			if err := Scan(stdin, "-", fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = Scan(file, path, fn)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
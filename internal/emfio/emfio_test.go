@@ -0,0 +1,85 @@
+package emfio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	const doc = `{"_aws":{"Timestamp":1,"CloudWatchMetrics":[]},"Latency":1}`
+
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "bare document",
+			line:     doc,
+			expected: doc,
+			ok:       true,
+		},
+		{
+			name:     "S3 export with timestamp prefix",
+			line:     "2024-01-01T00:00:00.000Z " + doc,
+			expected: doc,
+			ok:       true,
+		},
+		{
+			name:     "JSON log event",
+			line:     `{"timestamp":1,"message":"` + strings.ReplaceAll(doc, `"`, `\"`) + `"}`,
+			expected: doc,
+			ok:       true,
+		},
+		{
+			name:     "malformed document",
+			line:     `{"_aws": {`,
+			expected: `{"_aws": {`,
+			ok:       true,
+		},
+		{
+			name: "plain text",
+			line: "START RequestId: 1234",
+		},
+		{
+			name: "JSON without metadata",
+			line: `{"level":"info","msg":"hello"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, ok := Extract([]byte(test.line))
+			if ok != test.ok {
+				t.Fatalf("Expected ok to be %v, got %v", test.ok, ok)
+			}
+			if string(data) != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, string(data))
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	input := "first line\n\n" + `{"_aws":{}}` + "\n"
+
+	var records []Record
+	err := Scan(strings.NewReader(input), "input.log", func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected blank lines to be skipped, got %d records", len(records))
+	}
+	if records[0].Data != nil || records[0].Line != 1 {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].Data == nil || records[1].Line != 3 || records[1].Source != "input.log" {
+		t.Errorf("Unexpected second record: %+v", records[1])
+	}
+}
@@ -0,0 +1,423 @@
+package emf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is the flush interval used when AggregatorConfig.Interval is not set.
+const DefaultFlushInterval = time.Minute
+
+// SourceDimension is the dimension added to aggregated metrics and instruments without
+// any dimension, since every EMF metric needs at least one dimension set.
+const SourceDimension = "Source"
+
+// SourceValue is the value of SourceDimension.
+const SourceValue = "emf"
+
+// AggregationMode selects how an Aggregator summarises the samples it collects.
+type AggregationMode int
+
+const (
+	// AggregateStatisticSet emits every metric as a StatisticSet (Max, Min, Count, Sum).
+	AggregateStatisticSet AggregationMode = iota
+	// AggregateDistribution emits every metric as a Distribution of values and counts,
+	// which preserves percentiles at the cost of larger events.
+	AggregateDistribution
+)
+
+// AggregatorConfig configures an Aggregator.
+type AggregatorConfig struct {
+	// Sink receives the aggregated metric logs. Defaults to a WriterSink on standard output.
+	Sink Sink
+	// Interval is the time between automatic flushes. Defaults to DefaultFlushInterval.
+	Interval time.Duration
+	// Mode selects the shape of the emitted metric values.
+	Mode AggregationMode
+	// OnError is called when an automatic flush fails. It may be nil.
+	OnError func(error)
+}
+
+// Aggregator accumulates metrics on the client side and emits one compact
+// MetricLog per namespace and dimension combination at every flush.
+// Samples are keyed by namespace, dimension sets, dimension values and metric name.
+// Properties of the incoming logs are not carried over to the aggregated output.
+type Aggregator struct {
+	config AggregatorConfig
+
+	mu     sync.Mutex
+	groups map[string]*aggregateGroup
+
+	// runMu guards stop and done. It is separate from mu so that Stop can wait for
+	// a running flush.
+	runMu sync.Mutex
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// aggregateGroup holds the metrics that share a namespace and dimensions.
+type aggregateGroup struct {
+	namespace     string
+	dimensionSets [][]string
+	dimensions    map[string]string
+	metrics       map[string]*aggregateMetric
+	order         []string
+}
+
+// aggregateMetric holds the samples collected for a single metric.
+type aggregateMetric struct {
+	unit       string
+	resolution *int
+	counts     map[float64]float64
+	stats      StatisticSet
+	// extra holds samples that arrived as statistic sets and therefore have no individual values.
+	extra StatisticSet
+}
+
+// NewAggregator creates a new Aggregator with the given configuration.
+// Call Start to flush periodically, or Flush to emit on demand.
+func NewAggregator(config AggregatorConfig) *Aggregator {
+	if config.Interval <= 0 {
+		config.Interval = DefaultFlushInterval
+	}
+	if config.Sink == nil {
+		config.Sink = NewWriterSink(os.Stdout)
+	}
+
+	return &Aggregator{
+		config: config,
+		groups: make(map[string]*aggregateGroup),
+	}
+}
+
+// Add folds all metrics of the given metric log into the aggregator, from every
+// metric directive of the log. Metric values may be numbers, slices of numbers,
+// StatisticSet or Distribution values; NaN and infinite values are ignored.
+func (a *Aggregator) Add(ml *MetricLog) error {
+	var errs []error
+	for _, directive := range ml.resolved().emf.Aws.CloudWatchMetrics {
+		if err := a.addDirective(ml, directive); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addDirective folds the metrics of a single metric directive into the aggregator.
+func (a *Aggregator) addDirective(ml *MetricLog, directive EmfFormatJsonAwsCloudWatchMetricsElem) error {
+	dimensions := make(map[string]string)
+	for _, dimSet := range directive.Dimensions {
+		for _, dim := range dimSet {
+			value, exists := ml.metrics[dim]
+			if !exists {
+				return fmt.Errorf("dimension '%s' is referenced but no value is provided", dim)
+			}
+			dimensions[dim] = fmt.Sprint(value)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	group := a.group(directive.Namespace, directive.Dimensions, dimensions)
+
+	var errs []error
+	for _, def := range directive.Metrics {
+		unit := UnitNone
+		if def.Unit != nil {
+			unit = *def.Unit
+		}
+		metric := group.metric(def.Name, unit, def.StorageResolution)
+
+		switch value := ml.metrics[def.Name].(type) {
+		case StatisticSet:
+			metric.merge(value)
+		case Distribution:
+			if len(value.Values) == 0 {
+				metric.merge(value.Statistics())
+				continue
+			}
+			for i, v := range value.Values {
+				count := 1.0
+				if i < len(value.Counts) {
+					count = value.Counts[i]
+				}
+				metric.observe(v, count)
+			}
+		default:
+			values, ok := toFloat64Slice(value)
+			if !ok {
+				errs = append(errs, fmt.Errorf("metric '%s' has a non-numeric value", def.Name))
+				continue
+			}
+			for _, v := range values {
+				metric.observe(v, 1)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Record adds a single sample without building a MetricLog first.
+// All keys of dimensions form a single dimension set. Samples without dimensions
+// get the SourceDimension.
+func (a *Aggregator) Record(namespace string, dimensions map[string]string, name string, value float64, unit string) {
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.group(namespace, [][]string{keys}, dimensions).metric(name, unit, nil).observe(value, 1)
+}
+
+// Flush emits the aggregated metrics to the sink and resets the aggregator.
+func (a *Aggregator) Flush() error {
+	a.mu.Lock()
+	groups := a.groups
+	a.groups = make(map[string]*aggregateGroup)
+	a.mu.Unlock()
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		for _, ml := range groups[key].metricLogs(a.config.Mode) {
+			if err := a.config.Sink.Emit(ml); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Start begins flushing the aggregator every configured interval in a background goroutine.
+// It does nothing if the aggregator is already started.
+func (a *Aggregator) Start() {
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+	if a.stop != nil {
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	a.stop, a.done = stop, done
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(a.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.Flush(); err != nil && a.config.OnError != nil {
+					a.config.OnError(err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background flushing started by Start and flushes any remaining metrics.
+func (a *Aggregator) Stop() error {
+	a.runMu.Lock()
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+		a.stop, a.done = nil, nil
+	}
+	a.runMu.Unlock()
+	return a.Flush()
+}
+
+// group returns the aggregate group for the given key, creating it if needed.
+// Groups without a non-empty dimension set get the SourceDimension.
+// The caller must hold a.mu.
+func (a *Aggregator) group(namespace string, dimensionSets [][]string, dimensions map[string]string) *aggregateGroup {
+	if !hasDimensions(dimensionSets) {
+		dimensionSets = [][]string{{SourceDimension}}
+		dimensions = map[string]string{SourceDimension: SourceValue}
+	}
+
+	key := aggregationKey(namespace, dimensionSets, dimensions)
+	group, exists := a.groups[key]
+	if !exists {
+		sets := make([][]string, len(dimensionSets))
+		for i, dimSet := range dimensionSets {
+			sets[i] = append([]string(nil), dimSet...)
+		}
+		dims := make(map[string]string, len(dimensions))
+		for k, v := range dimensions {
+			dims[k] = v
+		}
+
+		group = &aggregateGroup{
+			namespace:     namespace,
+			dimensionSets: sets,
+			dimensions:    dims,
+			metrics:       make(map[string]*aggregateMetric),
+		}
+		a.groups[key] = group
+	}
+	return group
+}
+
+// hasDimensions reports whether any of the dimension sets is non-empty.
+func hasDimensions(dimensionSets [][]string) bool {
+	for _, dimSet := range dimensionSets {
+		if len(dimSet) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregationKey builds a stable key from the namespace, the dimension sets and the dimension values.
+func aggregationKey(namespace string, dimensionSets [][]string, dimensions map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString(namespace)
+
+	for _, dimSet := range dimensionSets {
+		sb.WriteByte(0)
+		sb.WriteString(strings.Join(dimSet, "\x01"))
+	}
+
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		sb.WriteByte(0)
+		sb.WriteString(key)
+		sb.WriteByte(1)
+		sb.WriteString(dimensions[key])
+	}
+
+	return sb.String()
+}
+
+// metric returns the aggregate for the named metric, creating it if needed.
+func (g *aggregateGroup) metric(name, unit string, resolution *int) *aggregateMetric {
+	metric, exists := g.metrics[name]
+	if !exists {
+		metric = &aggregateMetric{
+			unit:   unit,
+			counts: make(map[float64]float64),
+		}
+		if resolution != nil {
+			r := *resolution
+			metric.resolution = &r
+		}
+		g.metrics[name] = metric
+		g.order = append(g.order, name)
+	}
+	return metric
+}
+
+// metricLogs renders the group as one or more metric logs. Distributions with more
+// distinct values than CloudWatch accepts in a single event are split across several logs.
+func (g *aggregateGroup) metricLogs(mode AggregationMode) []*MetricLog {
+	var logs []*MetricLog
+	logAt := func(i int) *MetricLog {
+		for len(logs) <= i {
+			ml := NewMetricLog(g.namespace)
+			for key, value := range g.dimensions {
+				ml.PutDimension(key, value)
+			}
+			for _, dimSet := range g.dimensionSets {
+				ml.WithDimensionSet(append([]string(nil), dimSet...))
+			}
+			logs = append(logs, ml)
+		}
+		return logs[i]
+	}
+
+	for _, name := range g.order {
+		metric := g.metrics[name]
+		if metric.stats.Count == 0 {
+			// Every sample was NaN or infinite
+			continue
+		}
+
+		var values []interface{}
+		if mode == AggregateDistribution {
+			for _, dist := range metric.distributions() {
+				values = append(values, dist)
+			}
+		} else {
+			values = append(values, metric.stats)
+		}
+
+		for i, value := range values {
+			ml := logAt(i)
+			if metric.resolution != nil {
+				ml.PutMetricWithResolution(name, value, metric.unit, *metric.resolution)
+			} else {
+				ml.PutMetric(name, value, metric.unit)
+			}
+		}
+	}
+
+	return logs
+}
+
+// observe records count occurrences of value. NaN and infinite values and counts
+// are ignored.
+func (m *aggregateMetric) observe(value, count float64) {
+	if !isFinite(value) || !isFinite(count) || count <= 0 {
+		return
+	}
+	m.counts[value] += count
+	m.stats.ObserveN(value, count)
+}
+
+// merge records a sample that has no individual values. Statistic sets with NaN
+// or infinite statistics are ignored.
+func (m *aggregateMetric) merge(stats StatisticSet) {
+	if !isFinite(stats.Max) || !isFinite(stats.Min) || !isFinite(stats.Count) || !isFinite(stats.Sum) {
+		return
+	}
+	m.stats.Merge(stats)
+	m.extra.Merge(stats)
+}
+
+// distributions splits the collected values into distributions of at most MaxValuesPerMetric
+// distinct values each. Samples without individual values are added to the first distribution.
+func (m *aggregateMetric) distributions() []Distribution {
+	if len(m.counts) == 0 {
+		return []Distribution{{
+			Values: []float64{},
+			Counts: []float64{},
+			Max:    m.extra.Max,
+			Min:    m.extra.Min,
+			Count:  m.extra.Count,
+			Sum:    m.extra.Sum,
+		}}
+	}
+
+	dists := NewDistribution(m.counts).Split()
+	stats := dists[0].Statistics()
+	stats.Merge(m.extra)
+	dists[0].Max, dists[0].Min, dists[0].Count, dists[0].Sum = stats.Max, stats.Min, stats.Count, stats.Sum
+	return dists
+}
@@ -0,0 +1,285 @@
+package emf
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectingSink returns a sink that stores every emitted metric log.
+func collectingSink() (Sink, func() []*MetricLog) {
+	var mu sync.Mutex
+	var logs []*MetricLog
+	sink := SinkFunc(func(ml *MetricLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, ml)
+		return nil
+	})
+	return sink, func() []*MetricLog {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*MetricLog(nil), logs...)
+	}
+}
+
+func newRequestLog(service string, latency float64) *MetricLog {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", service)
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Latency", latency, UnitMilliseconds)
+	ml.PutMetric("Requests", 1, UnitCount)
+	return ml
+}
+
+func TestAggregatorStatisticSet(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink})
+
+	for _, latency := range []float64{10, 20, 30} {
+		if err := agg.Add(newRequestLog("API", latency)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := agg.Add(newRequestLog("Worker", 5)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+
+	emitted := logs()
+	if len(emitted) != 2 {
+		t.Fatalf("Expected 2 aggregated logs, got %d", len(emitted))
+	}
+
+	api := emitted[0]
+	if api.metrics["Service"] != "API" {
+		t.Fatalf("Expected first log to be for API, got %v", api.metrics["Service"])
+	}
+
+	latency, ok := api.metrics["Latency"].(StatisticSet)
+	if !ok {
+		t.Fatalf("Expected Latency to be a StatisticSet, got %T", api.metrics["Latency"])
+	}
+	expected := StatisticSet{Max: 30, Min: 10, Count: 3, Sum: 60}
+	if latency != expected {
+		t.Errorf("Expected %+v, got %+v", expected, latency)
+	}
+
+	requests := api.metrics["Requests"].(StatisticSet)
+	if requests.Sum != 3 || requests.Count != 3 {
+		t.Errorf("Expected 3 requests, got %+v", requests)
+	}
+
+	if err := api.Validate(); err != nil {
+		t.Errorf("Expected aggregated log to be valid, got: %v", err)
+	}
+
+	// The aggregator must be empty after a flush.
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+	if len(logs()) != 2 {
+		t.Errorf("Expected no additional logs after an empty flush, got %d", len(logs())-2)
+	}
+}
+
+func TestAggregatorDistribution(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink, Mode: AggregateDistribution})
+
+	for _, latency := range []float64{10, 20, 10, 10} {
+		agg.Record("TestNamespace", map[string]string{"Service": "API"}, "Latency", latency, UnitMilliseconds)
+	}
+
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+
+	emitted := logs()
+	if len(emitted) != 1 {
+		t.Fatalf("Expected 1 aggregated log, got %d", len(emitted))
+	}
+
+	jsonData, err := emitted[0].MarshalJSON()
+	if err != nil {
+		t.Fatalf("Error marshaling to JSON: %v", err)
+	}
+
+	var parsed struct {
+		Latency Distribution
+	}
+	if err := json.Unmarshal(jsonData, &parsed); err != nil {
+		t.Fatalf("Error parsing JSON: %v", err)
+	}
+
+	dist := parsed.Latency
+	if len(dist.Values) != 2 || dist.Values[0] != 10 || dist.Values[1] != 20 {
+		t.Errorf("Expected values [10 20], got %v", dist.Values)
+	}
+	if len(dist.Counts) != 2 || dist.Counts[0] != 3 || dist.Counts[1] != 1 {
+		t.Errorf("Expected counts [3 1], got %v", dist.Counts)
+	}
+	if dist.Count != 4 || dist.Sum != 50 || dist.Min != 10 || dist.Max != 20 {
+		t.Errorf("Unexpected distribution statistics: %+v", dist)
+	}
+}
+
+func TestAggregatorSplitsLargeDistributions(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink, Mode: AggregateDistribution})
+
+	for i := 0; i < MaxValuesPerMetric+10; i++ {
+		agg.Record("TestNamespace", map[string]string{"Service": "API"}, "Latency", float64(i), UnitMilliseconds)
+	}
+
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+
+	emitted := logs()
+	if len(emitted) != 2 {
+		t.Fatalf("Expected distribution to be split across 2 logs, got %d", len(emitted))
+	}
+
+	var total float64
+	for _, ml := range emitted {
+		dist := ml.metrics["Latency"].(Distribution)
+		if len(dist.Values) > MaxValuesPerMetric {
+			t.Errorf("Expected at most %d values per log, got %d", MaxValuesPerMetric, len(dist.Values))
+		}
+		total += dist.Count
+	}
+	if total != MaxValuesPerMetric+10 {
+		t.Errorf("Expected a total count of %d, got %v", MaxValuesPerMetric+10, total)
+	}
+}
+
+func TestAggregatorRejectsNonNumericValues(t *testing.T) {
+	sink, _ := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink})
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Latency", "fast", UnitMilliseconds)
+
+	if err := agg.Add(ml); err == nil {
+		t.Error("Expected an error for a non-numeric metric value")
+	}
+}
+
+func TestAggregatorWithoutDimensions(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink})
+
+	agg.Record("TestNamespace", nil, "Requests", 1, UnitCount)
+	ml := NewMetricLog("TestNamespace")
+	ml.PutMetric("Requests", 2, UnitCount)
+	if err := agg.Add(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Expected dimensionless samples to flush, got: %v", err)
+	}
+	if len(logs()) != 1 {
+		t.Fatalf("Expected 1 metric log, got %d", len(logs()))
+	}
+
+	flushed := logs()[0]
+	if value, _ := flushed.Value(SourceDimension); value != SourceValue {
+		t.Errorf("Expected the %s dimension, got %v", SourceDimension, value)
+	}
+	if sum := flushed.metrics["Requests"].(StatisticSet).Sum; sum != 3 {
+		t.Errorf("Expected a sum of 3, got %v", sum)
+	}
+}
+
+func TestAggregatorAddsEveryDirective(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink})
+
+	ml, err := ParseMetricLog([]byte(`{"_aws":{"Timestamp":1700000000000,"CloudWatchMetrics":[` +
+		`{"Namespace":"First","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]},` +
+		`{"Namespace":"Second","Dimensions":[["Service"]],"Metrics":[{"Name":"Requests","Unit":"Count"}]}]},` +
+		`"Service":"API","Latency":42,"Requests":3}`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if err := agg.Add(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	namespaces := make(map[string]bool)
+	for _, flushed := range logs() {
+		namespaces[flushed.emf.Aws.CloudWatchMetrics[0].Namespace] = true
+	}
+	if len(logs()) != 2 || !namespaces["First"] || !namespaces["Second"] {
+		t.Errorf("Expected a log for each directive, got %v", namespaces)
+	}
+}
+
+func TestAggregatorDropsNonFiniteMetrics(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink})
+
+	dims := map[string]string{"Service": "API"}
+	agg.Record("TestNamespace", dims, "Latency", math.NaN(), UnitMilliseconds)
+	agg.Record("TestNamespace", dims, "Latency", math.Inf(1), UnitMilliseconds)
+	agg.Record("TestNamespace", dims, "Requests", 1, UnitCount)
+	if err := agg.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(logs()) != 1 {
+		t.Fatalf("Expected 1 metric log, got %d", len(logs()))
+	}
+	if _, exists := logs()[0].metrics["Latency"]; exists {
+		t.Errorf("Expected the metric without finite values to be dropped, got %v", logs()[0].metrics["Latency"])
+	}
+}
+
+func TestAggregatorDefaultSink(t *testing.T) {
+	agg := NewAggregator(AggregatorConfig{})
+	if agg.config.Sink == nil {
+		t.Error("Expected a default sink")
+	}
+}
+
+func TestAggregatorStartStop(t *testing.T) {
+	sink, logs := collectingSink()
+	agg := NewAggregator(AggregatorConfig{Sink: sink, Interval: 10 * time.Millisecond})
+	agg.Start()
+	agg.Start() // ignored, already started
+
+	agg.Record("TestNamespace", map[string]string{"Service": "API"}, "Requests", 1, UnitCount)
+
+	deadline := time.Now().Add(time.Second)
+	for len(logs()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(logs()) == 0 {
+		t.Fatal("Expected the aggregator to flush periodically")
+	}
+
+	agg.Record("TestNamespace", map[string]string{"Service": "API"}, "Requests", 1, UnitCount)
+	if err := agg.Stop(); err != nil {
+		t.Fatalf("Unexpected error on stop: %v", err)
+	}
+
+	var total float64
+	for _, ml := range logs() {
+		total += ml.metrics["Requests"].(StatisticSet).Sum
+	}
+	if total != 2 {
+		t.Errorf("Expected a total of 2 requests across flushes, got %v", total)
+	}
+}
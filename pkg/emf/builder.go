@@ -1,8 +1,14 @@
 package emf
 
+import (
+	"context"
+	"time"
+)
+
 // MetricLogBuilder provides a fluent builder interface for creating EMF metric logs.
 type MetricLogBuilder struct {
-	metricLog *MetricLog
+	metricLog       *MetricLog
+	onRollupWarning func(RollupWarning)
 }
 
 // NewMetricLogBuilder creates a new MetricLogBuilder for the given MetricLog.
@@ -25,6 +31,18 @@ func (b *MetricLogBuilder) DimensionSet(dimensions []string) *MetricLogBuilder {
 	return b
 }
 
+// DefaultDimension adds a default dimension that is prepended to every dimension set.
+func (b *MetricLogBuilder) DefaultDimension(key, value string) *MetricLogBuilder {
+	b.metricLog.PutDefaultDimension(key, value)
+	return b
+}
+
+// SetDimensions replaces all dimension sets, including the default dimensions.
+func (b *MetricLogBuilder) SetDimensions(dimensionSets ...[]string) *MetricLogBuilder {
+	b.metricLog.SetDimensions(dimensionSets...)
+	return b
+}
+
 // Metric adds a metric with the given name, value, and unit to the log.
 func (b *MetricLogBuilder) Metric(name string, value interface{}, unit string) *MetricLogBuilder {
 	b.metricLog.PutMetric(name, value, unit)
@@ -41,8 +59,70 @@ func (b *MetricLogBuilder) MetricWithResolution(name string, value interface{},
 
 // Property adds a custom property to the log.
 // Properties are not reported as metrics but appear in the log events.
+// The largest properties are truncated if the event exceeds MaxEventSize.
 func (b *MetricLogBuilder) Property(key string, value interface{}) *MetricLogBuilder {
-	b.metricLog.metrics[key] = value
+	b.metricLog.PutProperty(key, value)
+	return b
+}
+
+// Object adds a structured property, serialized as JSON.
+func (b *MetricLogBuilder) Object(key string, v interface{}) *MetricLogBuilder {
+	b.metricLog.PutObject(key, v)
+	return b
+}
+
+// Error adds an error property with the message, type and wrapped chain of the error.
+func (b *MetricLogBuilder) Error(key string, err error) *MetricLogBuilder {
+	b.metricLog.PutError(key, err)
+	return b
+}
+
+// RequestID adds the request ID as the RequestId property.
+func (b *MetricLogBuilder) RequestID(id string) *MetricLogBuilder {
+	b.metricLog.PutRequestID(id)
+	return b
+}
+
+// TraceID adds the trace ID as the TraceId property.
+func (b *MetricLogBuilder) TraceID(id string) *MetricLogBuilder {
+	b.metricLog.PutTraceID(id)
+	return b
+}
+
+// TraceContext adds the trace ID of ctx as the TraceId property.
+func (b *MetricLogBuilder) TraceContext(ctx context.Context) *MetricLogBuilder {
+	b.metricLog.PutTraceContext(ctx)
+	return b
+}
+
+// XRayTraceContext adds the trace ID of ctx in X-Ray format as the xray_trace_id property.
+func (b *MetricLogBuilder) XRayTraceContext(ctx context.Context) *MetricLogBuilder {
+	b.metricLog.PutXRayTraceContext(ctx)
+	return b
+}
+
+// Time adds a time property in RFC 3339 format.
+func (b *MetricLogBuilder) Time(key string, t time.Time) *MetricLogBuilder {
+	b.metricLog.PutTime(key, t)
+	return b
+}
+
+// Timestamp sets the timestamp of the log.
+func (b *MetricLogBuilder) Timestamp(t time.Time) *MetricLogBuilder {
+	b.metricLog.SetTimestamp(t)
+	return b
+}
+
+// ValidationPolicy sets how the log is validated when it is marshaled.
+func (b *MetricLogBuilder) ValidationPolicy(policy ValidationPolicy, onDrop func(Drop)) *MetricLogBuilder {
+	b.metricLog.SetValidationPolicy(policy, onDrop)
+	return b
+}
+
+// PreserveOrder keeps dimension sets and metric definitions in declaration order
+// when the log is serialized.
+func (b *MetricLogBuilder) PreserveOrder(preserve bool) *MetricLogBuilder {
+	b.metricLog.SetPreserveOrder(preserve)
 	return b
 }
 
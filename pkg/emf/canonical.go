@@ -0,0 +1,111 @@
+package emf
+
+import (
+	"sort"
+	"strings"
+)
+
+// SetPreserveOrder controls the order of the dimension sets and metric definitions
+// of the serialized log. Duplicate dimension sets, which contain the same
+// dimensions in any order, and repeated metric definitions are always removed;
+// a dimension repeated within a set fails validation.
+// By default the remaining dimension sets and metric definitions are sorted, as
+// are the dimensions within each set after the default dimensions, so that equal
+// logs serialize identically; with preserve set they keep the order in which they
+// were declared.
+func (ml *MetricLog) SetPreserveOrder(preserve bool) *MetricLog {
+	ml.preserveOrder = preserve
+	return ml
+}
+
+// resolved returns the metric log as it will be serialized: with the default
+// dimensions applied, and its dimension sets and metric definitions deduplicated
+// and put in order. The returned log shares its values with ml.
+func (ml *MetricLog) resolved() *MetricLog {
+	resolved := *ml.withDefaults()
+
+	var defaults []string
+	if !ml.replaceDefaults {
+		defaults = ml.defaultDimensions
+	}
+
+	directives := make([]EmfFormatJsonAwsCloudWatchMetricsElem, len(resolved.emf.Aws.CloudWatchMetrics))
+	for i, directive := range resolved.emf.Aws.CloudWatchMetrics {
+		directive.Dimensions = canonicalDimensionSets(directive.Dimensions, defaults, ml.preserveOrder)
+		directive.Metrics = canonicalMetrics(directive.Metrics, ml.preserveOrder)
+		directives[i] = directive
+	}
+	resolved.emf.Aws.CloudWatchMetrics = directives
+
+	return &resolved
+}
+
+// canonicalDimensionSets removes repeated dimensions within each set and sets that
+// contain the same dimensions as an earlier set. Unless preserveOrder is set, the
+// dimensions of each set are sorted by name, except for the leading default
+// dimensions, and the sets by size, then by their dimensions.
+func canonicalDimensionSets(dimensionSets [][]string, defaults []string, preserveOrder bool) [][]string {
+	result := make([][]string, 0, len(dimensionSets))
+	seenSets := make(map[string]bool, len(dimensionSets))
+	for _, dimSet := range dimensionSets {
+		unique := make([]string, 0, len(dimSet))
+		seen := make(map[string]bool, len(dimSet))
+		for _, dim := range dimSet {
+			if !seen[dim] {
+				seen[dim] = true
+				unique = append(unique, dim)
+			}
+		}
+
+		sorted := append([]string(nil), unique...)
+		sort.Strings(sorted)
+		key := strings.Join(sorted, "\x00")
+		if seenSets[key] {
+			continue
+		}
+		seenSets[key] = true
+
+		if !preserveOrder {
+			// Default dimensions lead every set; withDefaults has put them first
+			prefix := 0
+			for prefix < len(unique) && prefix < len(defaults) && unique[prefix] == defaults[prefix] {
+				prefix++
+			}
+			sort.Strings(unique[prefix:])
+		}
+		result = append(result, unique)
+	}
+
+	if !preserveOrder {
+		sort.SliceStable(result, func(i, j int) bool {
+			if len(result[i]) != len(result[j]) {
+				return len(result[i]) < len(result[j])
+			}
+			return strings.Join(result[i], "\x00") < strings.Join(result[j], "\x00")
+		})
+	}
+	return result
+}
+
+// canonicalMetrics removes repeated metric definitions, keeping the last definition
+// of each metric at the position of the first. Unless preserveOrder is set, the
+// definitions are sorted by name.
+func canonicalMetrics(metrics []EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem, preserveOrder bool) []EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem {
+	result := make([]EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem, 0, len(metrics))
+	index := make(map[string]int, len(metrics))
+	for _, metric := range metrics {
+		if i, exists := index[metric.Name]; exists {
+			result[i] = metric
+			continue
+		}
+		index[metric.Name] = len(result)
+		result = append(result, metric)
+	}
+
+	if !preserveOrder {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].Name < result[j].Name
+		})
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package emf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalOrder(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("Service", "API")
+	ml.PutDimension("Region", "eu-west-1")
+	ml.PutDimension("Operation", "GetUser")
+	ml.WithDimensionSet([]string{"Region", "Operation"})
+	ml.WithDimensionSet([]string{"Operation", "Region"})
+	ml.WithDimensionSet([]string{"Operation"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	ml.PutMetric("Count", 1, UnitNone)
+	ml.PutMetric("Count", 2, UnitCount)
+
+	directive := ml.Metadata().CloudWatchMetrics[0]
+	expected := [][]string{
+		{"Service", "Operation"},
+		{"Service", "Operation", "Region"},
+	}
+	if !reflect.DeepEqual(directive.Dimensions, expected) {
+		t.Errorf("Expected dimension sets %v, got %v", expected, directive.Dimensions)
+	}
+
+	if len(directive.Metrics) != 2 || directive.Metrics[0].Name != "Count" || directive.Metrics[1].Name != "Latency" {
+		t.Fatalf("Expected sorted metric definitions without repeats, got %+v", directive.Metrics)
+	}
+	if *directive.Metrics[0].Unit != UnitCount {
+		t.Errorf("Expected the last definition of a repeated metric, got unit %s", *directive.Metrics[0].Unit)
+	}
+
+	// Equal logs declared in a different order serialize identically
+	other := NewMetricLog("TestNamespace")
+	other.PutDefaultDimension("Service", "API")
+	other.PutDimension("Operation", "GetUser")
+	other.PutDimension("Region", "eu-west-1")
+	other.WithDimensionSet([]string{"Operation"})
+	other.WithDimensionSet([]string{"Operation", "Region"})
+	other.PutMetric("Count", 2, UnitCount)
+	other.PutMetric("Latency", 42.0, UnitMilliseconds)
+	other.SetTimestamp(ml.Timestamp())
+	if ml.String() != other.String() {
+		t.Errorf("Expected identical serializations, got\n%s\n%s", ml.String(), other.String())
+	}
+}
+
+func TestPreserveOrder(t *testing.T) {
+	ml := NewMetricLog("TestNamespace").SetPreserveOrder(true)
+	ml.PutDimension("Region", "eu-west-1")
+	ml.PutDimension("Operation", "GetUser")
+	ml.WithDimensionSet([]string{"Region", "Operation"})
+	ml.WithDimensionSet([]string{"Operation", "Region"})
+	ml.WithDimensionSet([]string{"Region"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	ml.PutMetric("Count", 1, UnitCount)
+	ml.PutMetric("Latency", 40.0, UnitMilliseconds)
+
+	directive := ml.Metadata().CloudWatchMetrics[0]
+	expected := [][]string{
+		{"Region", "Operation"},
+		{"Region"},
+	}
+	if !reflect.DeepEqual(directive.Dimensions, expected) {
+		t.Errorf("Expected dimension sets in declaration order, got %v", directive.Dimensions)
+	}
+	if len(directive.Metrics) != 2 || directive.Metrics[0].Name != "Latency" || directive.Metrics[1].Name != "Count" {
+		t.Errorf("Expected metric definitions in declaration order, got %+v", directive.Metrics)
+	}
+}
@@ -0,0 +1,290 @@
+package emf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cardinality guard defaults
+const (
+	DefaultCardinalityLimit = 100
+	DefaultOverflowValue    = "__other__"
+)
+
+// ErrCardinalityExceeded is returned by a CardinalityGuard configured with OverflowError
+// when a dimension receives more distinct values than its limit allows.
+var ErrCardinalityExceeded = errors.New("dimension cardinality limit exceeded")
+
+// OverflowAction selects what a CardinalityGuard does with a dimension value
+// that would exceed the limit of its dimension key.
+type OverflowAction int
+
+const (
+	// OverflowReplace replaces the value with the configured overflow value.
+	OverflowReplace OverflowAction = iota
+	// OverflowDrop removes the dimension from all dimension sets. The value is kept as a property,
+	// and logs left without dimensions get the SourceDimension.
+	OverflowDrop
+	// OverflowError rejects the metric log with ErrCardinalityExceeded. The values of
+	// a rejected log are not recorded.
+	OverflowError
+)
+
+// CardinalityGuardConfig configures a CardinalityGuard.
+type CardinalityGuardConfig struct {
+	// Limit is the maximum number of distinct values per dimension key.
+	// Defaults to DefaultCardinalityLimit.
+	Limit int
+	// Limits overrides Limit for individual dimension keys.
+	Limits map[string]int
+	// Action selects what happens to values beyond the limit.
+	Action OverflowAction
+	// OverflowValue replaces values beyond the limit when Action is OverflowReplace.
+	// Defaults to DefaultOverflowValue.
+	OverflowValue string
+}
+
+// CardinalityStats counts the interventions of a CardinalityGuard for one dimension key.
+type CardinalityStats struct {
+	DistinctValues int
+	Replaced       int64
+	Dropped        int64
+	Rejected       int64
+}
+
+// CardinalityGuard tracks the distinct values seen for every dimension key across
+// metric logs and stops runaway dimensions from creating unbounded numbers of metrics.
+type CardinalityGuard struct {
+	config CardinalityGuardConfig
+
+	mu    sync.Mutex
+	seen  map[string]map[string]struct{}
+	stats map[string]*CardinalityStats
+}
+
+// NewCardinalityGuard creates a new CardinalityGuard with the given configuration.
+func NewCardinalityGuard(config CardinalityGuardConfig) *CardinalityGuard {
+	if config.Limit <= 0 {
+		config.Limit = DefaultCardinalityLimit
+	}
+	if config.OverflowValue == "" {
+		config.OverflowValue = DefaultOverflowValue
+	}
+
+	return &CardinalityGuard{
+		config: config,
+		seen:   make(map[string]map[string]struct{}),
+		stats:  make(map[string]*CardinalityStats),
+	}
+}
+
+// Apply checks every dimension referenced by the metric log against its limit
+// and modifies the log according to the configured action.
+func (g *CardinalityGuard) Apply(ml *MetricLog) error {
+	var keys []string
+	referenced := make(map[string]bool)
+	for _, dimSet := range ml.resolved().emf.Aws.CloudWatchMetrics[0].Dimensions {
+		for _, dim := range dimSet {
+			if !referenced[dim] {
+				referenced[dim] = true
+				keys = append(keys, dim)
+			}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Check every key first, so that a rejected log does not record any values
+	var admitted, overflowing []string
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, exists := ml.metrics[key]
+		if !exists {
+			continue
+		}
+		values[key] = fmt.Sprint(value)
+		if g.admits(key, values[key]) {
+			admitted = append(admitted, key)
+		} else {
+			overflowing = append(overflowing, key)
+		}
+	}
+
+	if len(overflowing) > 0 && g.config.Action == OverflowError {
+		for _, key := range overflowing {
+			g.statsFor(key).Rejected++
+		}
+		return fmt.Errorf("%w for %s", ErrCardinalityExceeded, strings.Join(overflowing, ", "))
+	}
+
+	for _, key := range admitted {
+		g.record(key, values[key])
+	}
+	if len(overflowing) == 0 {
+		return nil
+	}
+
+	if g.config.Action == OverflowDrop {
+		for _, key := range overflowing {
+			g.statsFor(key).Dropped++
+		}
+		directive := &ml.emf.Aws.CloudWatchMetrics[0]
+		directive.Dimensions = removeDimensions(directive.Dimensions, overflowing)
+		for _, key := range overflowing {
+			ml.removeDefaultDimension(key)
+		}
+
+		// Logs that lost all their dimensions get the SourceDimension
+		if len(ml.resolved().emf.Aws.CloudWatchMetrics[0].Dimensions) == 0 {
+			ml.PutDimension(SourceDimension, SourceValue)
+			directive.Dimensions = [][]string{{SourceDimension}}
+		}
+		return nil
+	}
+
+	for _, key := range overflowing {
+		g.statsFor(key).Replaced++
+		ml.metrics[key] = g.config.OverflowValue
+	}
+	return nil
+}
+
+// admits reports whether the value is known for the dimension key or within its limit.
+// The caller must hold g.mu.
+func (g *CardinalityGuard) admits(key, value string) bool {
+	values := g.seen[key]
+	if _, exists := values[value]; exists {
+		return true
+	}
+
+	limit := g.config.Limit
+	if keyLimit, exists := g.config.Limits[key]; exists {
+		limit = keyLimit
+	}
+	return len(values) < limit
+}
+
+// record adds the value to the values seen for the dimension key.
+// The caller must hold g.mu.
+func (g *CardinalityGuard) record(key, value string) {
+	values, exists := g.seen[key]
+	if !exists {
+		values = make(map[string]struct{})
+		g.seen[key] = values
+	}
+	values[value] = struct{}{}
+	g.statsFor(key).DistinctValues = len(values)
+}
+
+// statsFor returns the statistics for the dimension key, creating them if needed.
+// The caller must hold g.mu.
+func (g *CardinalityGuard) statsFor(key string) *CardinalityStats {
+	stats, exists := g.stats[key]
+	if !exists {
+		stats = &CardinalityStats{}
+		g.stats[key] = stats
+	}
+	return stats
+}
+
+// removeDimensions removes the given keys from every dimension set, dropping sets that
+// become empty or duplicate an earlier set.
+func removeDimensions(dimensionSets [][]string, keys []string) [][]string {
+	remove := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	result := make([][]string, 0, len(dimensionSets))
+	seen := make(map[string]bool)
+	for _, dimSet := range dimensionSets {
+		var kept []string
+		for _, dim := range dimSet {
+			if !remove[dim] {
+				kept = append(kept, dim)
+			}
+		}
+
+		signature := strings.Join(kept, "\x00")
+		if len(kept) == 0 || seen[signature] {
+			continue
+		}
+		seen[signature] = true
+		result = append(result, kept)
+	}
+	return result
+}
+
+// Stats returns a snapshot of the guard's statistics per dimension key.
+func (g *CardinalityGuard) Stats() map[string]CardinalityStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := make(map[string]CardinalityStats, len(g.stats))
+	for key, s := range g.stats {
+		stats[key] = *s
+	}
+	return stats
+}
+
+// Report renders the guard's statistics as metric logs in the given namespace,
+// one per dimension key, using the dimension key itself as the DimensionKey dimension.
+func (g *CardinalityGuard) Report(namespace string) []*MetricLog {
+	stats := g.Stats()
+
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	logs := make([]*MetricLog, 0, len(keys))
+	for _, key := range keys {
+		s := stats[key]
+		ml := NewMetricLog(namespace)
+		ml.PutDimension("DimensionKey", key)
+		ml.WithDimensionSet([]string{"DimensionKey"})
+		ml.PutMetric("DistinctValues", s.DistinctValues, UnitCount)
+		ml.PutMetric("CardinalityReplaced", s.Replaced, UnitCount)
+		ml.PutMetric("CardinalityDropped", s.Dropped, UnitCount)
+		ml.PutMetric("CardinalityRejected", s.Rejected, UnitCount)
+		logs = append(logs, ml)
+	}
+	return logs
+}
+
+// Reset forgets all tracked values and statistics, for example at the start of a new billing period.
+func (g *CardinalityGuard) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.seen = make(map[string]map[string]struct{})
+	g.stats = make(map[string]*CardinalityStats)
+}
+
+// CardinalitySink is a Sink that passes every metric log through a CardinalityGuard
+// before forwarding it.
+type CardinalitySink struct {
+	next  Sink
+	guard *CardinalityGuard
+}
+
+// NewCardinalitySink creates a new CardinalitySink that forwards guarded logs to next.
+func NewCardinalitySink(next Sink, guard *CardinalityGuard) *CardinalitySink {
+	return &CardinalitySink{
+		next:  next,
+		guard: guard,
+	}
+}
+
+// Emit applies the guard to the metric log and forwards it unless the guard rejects it.
+func (s *CardinalitySink) Emit(ml *MetricLog) error {
+	if err := s.guard.Apply(ml); err != nil {
+		return err
+	}
+	return s.next.Emit(ml)
+}
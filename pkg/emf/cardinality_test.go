@@ -0,0 +1,186 @@
+package emf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func newUserLog(user string) *MetricLog {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.PutDimension("UserId", user)
+	ml.WithDimensionSet([]string{"Service"})
+	ml.WithDimensionSet([]string{"Service", "UserId"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	return ml
+}
+
+func TestCardinalityGuardReplace(t *testing.T) {
+	guard := NewCardinalityGuard(CardinalityGuardConfig{Limit: 2})
+
+	for i := 0; i < 5; i++ {
+		ml := newUserLog(fmt.Sprintf("user-%d", i))
+		if err := guard.Apply(ml); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expected := fmt.Sprintf("user-%d", i)
+		if i >= 2 {
+			expected = DefaultOverflowValue
+		}
+		if ml.metrics["UserId"] != expected {
+			t.Errorf("Expected UserId to be %s, got %v", expected, ml.metrics["UserId"])
+		}
+	}
+
+	// Values seen before the limit was reached remain allowed.
+	ml := newUserLog("user-1")
+	if err := guard.Apply(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ml.metrics["UserId"] != "user-1" {
+		t.Errorf("Expected a known value to be kept, got %v", ml.metrics["UserId"])
+	}
+
+	stats := guard.Stats()
+	if stats["UserId"].Replaced != 3 || stats["UserId"].DistinctValues != 2 {
+		t.Errorf("Unexpected UserId stats: %+v", stats["UserId"])
+	}
+	if stats["Service"].Replaced != 0 || stats["Service"].DistinctValues != 1 {
+		t.Errorf("Unexpected Service stats: %+v", stats["Service"])
+	}
+}
+
+func TestCardinalityGuardDrop(t *testing.T) {
+	guard := NewCardinalityGuard(CardinalityGuardConfig{
+		Limits: map[string]int{"UserId": 1},
+		Action: OverflowDrop,
+	})
+
+	if err := guard.Apply(newUserLog("user-0")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ml := newUserLog("user-1")
+	if err := guard.Apply(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dims := ml.emf.Aws.CloudWatchMetrics[0].Dimensions
+	if len(dims) != 1 || len(dims[0]) != 1 || dims[0][0] != "Service" {
+		t.Errorf("Expected only the [Service] dimension set to remain, got %v", dims)
+	}
+	if ml.metrics["UserId"] != "user-1" {
+		t.Errorf("Expected the dropped dimension to remain as a property, got %v", ml.metrics["UserId"])
+	}
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected a valid log after dropping, got: %v", err)
+	}
+	if guard.Stats()["UserId"].Dropped != 1 {
+		t.Errorf("Expected 1 dropped dimension, got %d", guard.Stats()["UserId"].Dropped)
+	}
+}
+
+func TestCardinalityGuardError(t *testing.T) {
+	next, logs := collectingSink()
+	guard := NewCardinalityGuard(CardinalityGuardConfig{Limit: 1, Action: OverflowError})
+	sink := NewCardinalitySink(next, guard)
+
+	if err := sink.Emit(newUserLog("user-0")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err := sink.Emit(newUserLog("user-1"))
+	if !errors.Is(err, ErrCardinalityExceeded) {
+		t.Fatalf("Expected ErrCardinalityExceeded, got %v", err)
+	}
+
+	if len(logs()) != 1 {
+		t.Errorf("Expected the rejected log not to be forwarded, got %d logs", len(logs()))
+	}
+	if guard.Stats()["UserId"].Rejected != 1 {
+		t.Errorf("Expected 1 rejection, got %d", guard.Stats()["UserId"].Rejected)
+	}
+}
+
+func TestCardinalityGuardErrorRecordsNothingForRejectedLogs(t *testing.T) {
+	guard := NewCardinalityGuard(CardinalityGuardConfig{
+		Limits: map[string]int{"A": 2, "B": 1},
+		Action: OverflowError,
+	})
+
+	newLog := func(a, b string) *MetricLog {
+		ml := NewMetricLog("TestNamespace")
+		ml.PutDimension("A", a)
+		ml.PutDimension("B", b)
+		ml.WithDimensionSet([]string{"A", "B"})
+		ml.PutMetric("Requests", 1, UnitCount)
+		return ml
+	}
+
+	if err := guard.Apply(newLog("a1", "b1")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := guard.Apply(newLog("a2", "b2")); !errors.Is(err, ErrCardinalityExceeded) {
+		t.Fatalf("Expected ErrCardinalityExceeded for B, got %v", err)
+	}
+	if err := guard.Apply(newLog("a3", "b1")); err != nil {
+		t.Errorf("Expected the rejected log not to use a slot of A, got: %v", err)
+	}
+	if distinct := guard.Stats()["A"].DistinctValues; distinct != 2 {
+		t.Errorf("Expected 2 distinct values of A, got %d", distinct)
+	}
+}
+
+func TestCardinalityGuardDropOnlyDimension(t *testing.T) {
+	guard := NewCardinalityGuard(CardinalityGuardConfig{Limit: 1, Action: OverflowDrop})
+
+	newLog := func(user string) *MetricLog {
+		ml := NewMetricLog("TestNamespace")
+		ml.PutDimension("UserId", user)
+		ml.WithDimensionSet([]string{"UserId"})
+		ml.PutMetric("Requests", 1, UnitCount)
+		return ml
+	}
+
+	if err := guard.Apply(newLog("user-0")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ml := newLog("user-1")
+	if err := guard.Apply(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected a valid log after dropping its only dimension, got: %v", err)
+	}
+	if value, _ := ml.Value(SourceDimension); value != SourceValue {
+		t.Errorf("Expected the %s dimension, got %v", SourceDimension, value)
+	}
+}
+
+func TestCardinalityGuardReport(t *testing.T) {
+	guard := NewCardinalityGuard(CardinalityGuardConfig{Limit: 1})
+	guard.Apply(newUserLog("user-0"))
+	guard.Apply(newUserLog("user-1"))
+
+	reports := guard.Report("CardinalityGuard")
+	if len(reports) != 2 {
+		t.Fatalf("Expected a report per dimension key, got %d", len(reports))
+	}
+
+	for _, ml := range reports {
+		if err := ml.Validate(); err != nil {
+			t.Errorf("Expected a valid report, got: %v", err)
+		}
+	}
+	if reports[1].metrics["DimensionKey"] != "UserId" || reports[1].metrics["CardinalityReplaced"] != int64(1) {
+		t.Errorf("Unexpected UserId report: %v", reports[1].metrics)
+	}
+
+	guard.Reset()
+	if len(guard.Stats()) != 0 {
+		t.Error("Expected no statistics after a reset")
+	}
+}
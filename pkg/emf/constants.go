@@ -39,11 +39,12 @@ const (
 
 // Validation constants
 const (
-	MaxDimensionSetSize    = 30
-	MaxDimensionNameLength = 250
-	MinDimensions          = 1
-	MaxNamespaceLength     = 1024
-	MinNamespaceLength     = 1
-	MaxMetricNameLength    = 1024
-	MinMetricNameLength    = 1
+	MaxDimensionSetSize     = 30
+	MaxDimensionNameLength  = 250
+	MaxDimensionValueLength = 1024
+	MinDimensions           = 1
+	MaxNamespaceLength      = 1024
+	MinNamespaceLength      = 1
+	MaxMetricNameLength     = 1024
+	MinMetricNameLength     = 1
 )
@@ -0,0 +1,250 @@
+package emf
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultTopDimensions is the number of top contributing dimensions reported per namespace.
+const DefaultTopDimensions = 5
+
+// PriceTier is a tier of the monthly custom metric price. Metrics up to UpTo
+// (cumulative over all tiers) cost PricePerMetric each; an UpTo of 0 means no limit.
+type PriceTier struct {
+	UpTo           int     `json:"upTo"`
+	PricePerMetric float64 `json:"pricePerMetric"`
+}
+
+// PriceTable is a tiered monthly price of custom metrics, ordered by UpTo.
+type PriceTable []PriceTier
+
+// DefaultPriceTable is the CloudWatch custom metric price in US East (N. Virginia)
+// at the time of writing, in USD per metric and month.
+var DefaultPriceTable = PriceTable{
+	{UpTo: 10000, PricePerMetric: 0.30},
+	{UpTo: 250000, PricePerMetric: 0.10},
+	{UpTo: 1000000, PricePerMetric: 0.05},
+	{UpTo: 0, PricePerMetric: 0.02},
+}
+
+// MonthlyCost returns the monthly price of the given number of custom metrics.
+func (p PriceTable) MonthlyCost(metrics int) float64 {
+	var cost float64
+	priced := 0
+	for _, tier := range p {
+		if priced >= metrics {
+			break
+		}
+		count := metrics - priced
+		if tier.UpTo > 0 && tier.UpTo-priced < count {
+			count = tier.UpTo - priced
+		}
+		if count <= 0 {
+			continue
+		}
+		cost += float64(count) * tier.PricePerMetric
+		priced += count
+	}
+	return cost
+}
+
+// CostEstimatorConfig configures a CostEstimator.
+type CostEstimatorConfig struct {
+	// Prices is the price table. Defaults to DefaultPriceTable.
+	Prices PriceTable
+	// TopDimensions is the number of top contributing dimensions reported per
+	// namespace. Defaults to DefaultTopDimensions.
+	TopDimensions int
+}
+
+// CostEstimate is the result of a CostEstimator.
+type CostEstimate struct {
+	// Events is the number of metric logs added.
+	Events int64 `json:"events"`
+	// Series is the number of unique metric series over all namespaces, which is
+	// the number of custom metrics CloudWatch bills per month if they keep being emitted.
+	Series int `json:"series"`
+	// MonthlyCost is the estimated monthly price of the series.
+	MonthlyCost float64             `json:"monthlyCost"`
+	Namespaces  []NamespaceEstimate `json:"namespaces"`
+}
+
+// NamespaceEstimate is the part of a CostEstimate that belongs to a namespace.
+type NamespaceEstimate struct {
+	Namespace string `json:"namespace"`
+	Series    int    `json:"series"`
+	// Metrics is the number of distinct metric names.
+	Metrics int `json:"metrics"`
+	// MonthlyCost is the namespace's share of the total cost, in proportion to its series.
+	MonthlyCost   float64                 `json:"monthlyCost"`
+	TopDimensions []DimensionContribution `json:"topDimensions"`
+}
+
+// DimensionContribution describes how much a dimension contributes to the series of a namespace.
+type DimensionContribution struct {
+	Name string `json:"name"`
+	// Series is the number of series that carry the dimension.
+	Series int `json:"series"`
+	// DistinctValues is the number of distinct values of the dimension.
+	DistinctValues int `json:"distinctValues"`
+}
+
+// CostEstimator counts the unique metric series a stream of metric logs creates,
+// using the same extraction rules as CloudWatch, and estimates their monthly cost.
+type CostEstimator struct {
+	config CostEstimatorConfig
+
+	mu         sync.Mutex
+	events     int64
+	namespaces map[string]*namespaceSeries
+}
+
+// namespaceSeries holds the series seen in a namespace.
+type namespaceSeries struct {
+	series     map[string]bool
+	metrics    map[string]bool
+	dimensions map[string]*dimensionSeries
+}
+
+// dimensionSeries holds the series and values seen for a dimension.
+type dimensionSeries struct {
+	series int
+	values map[string]bool
+}
+
+// NewCostEstimator creates a new CostEstimator with the given configuration.
+func NewCostEstimator(config CostEstimatorConfig) *CostEstimator {
+	if config.Prices == nil {
+		config.Prices = DefaultPriceTable
+	}
+	if config.TopDimensions <= 0 {
+		config.TopDimensions = DefaultTopDimensions
+	}
+
+	return &CostEstimator{
+		config:     config,
+		namespaces: make(map[string]*namespaceSeries),
+	}
+}
+
+// Add counts the series the metric log creates.
+func (e *CostEstimator) Add(ml *MetricLog) {
+	datums := ExtractMetrics(ml)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events++
+	for _, datum := range datums {
+		ns, exists := e.namespaces[datum.Namespace]
+		if !exists {
+			ns = &namespaceSeries{
+				series:     make(map[string]bool),
+				metrics:    make(map[string]bool),
+				dimensions: make(map[string]*dimensionSeries),
+			}
+			e.namespaces[datum.Namespace] = ns
+		}
+
+		key := datum.SeriesKey()
+		if ns.series[key] {
+			continue
+		}
+		ns.series[key] = true
+		ns.metrics[datum.MetricName] = true
+
+		for name, value := range datum.Dimensions {
+			dim, exists := ns.dimensions[name]
+			if !exists {
+				dim = &dimensionSeries{values: make(map[string]bool)}
+				ns.dimensions[name] = dim
+			}
+			dim.series++
+			dim.values[value] = true
+		}
+	}
+}
+
+// AddJSON parses and validates a serialized EMF event and counts the series it creates.
+func (e *CostEstimator) AddJSON(data []byte) error {
+	ml, err := ParseMetricLog(data)
+	if err != nil {
+		return err
+	}
+	if err := ml.Validate(); err != nil {
+		return err
+	}
+	e.Add(ml)
+	return nil
+}
+
+// Estimate returns the estimate for the metric logs added so far. Namespaces are
+// ordered by series count, most series first.
+func (e *CostEstimator) Estimate() CostEstimate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	estimate := CostEstimate{Events: e.events}
+	for namespace, ns := range e.namespaces {
+		estimate.Series += len(ns.series)
+		estimate.Namespaces = append(estimate.Namespaces, NamespaceEstimate{
+			Namespace:     namespace,
+			Series:        len(ns.series),
+			Metrics:       len(ns.metrics),
+			TopDimensions: topDimensions(ns.dimensions, e.config.TopDimensions),
+		})
+	}
+
+	estimate.MonthlyCost = e.config.Prices.MonthlyCost(estimate.Series)
+	for i := range estimate.Namespaces {
+		ns := &estimate.Namespaces[i]
+		ns.MonthlyCost = estimate.MonthlyCost * float64(ns.Series) / float64(estimate.Series)
+	}
+
+	sort.Slice(estimate.Namespaces, func(i, j int) bool {
+		a, b := estimate.Namespaces[i], estimate.Namespaces[j]
+		if a.Series != b.Series {
+			return a.Series > b.Series
+		}
+		return a.Namespace < b.Namespace
+	})
+	return estimate
+}
+
+// Reset forgets all series seen so far.
+func (e *CostEstimator) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = 0
+	e.namespaces = make(map[string]*namespaceSeries)
+}
+
+// topDimensions returns the n dimensions that carry the most series, breaking ties
+// by the number of distinct values.
+func topDimensions(dimensions map[string]*dimensionSeries, n int) []DimensionContribution {
+	contributions := make([]DimensionContribution, 0, len(dimensions))
+	for name, dim := range dimensions {
+		contributions = append(contributions, DimensionContribution{
+			Name:           name,
+			Series:         dim.series,
+			DistinctValues: len(dim.values),
+		})
+	}
+
+	sort.Slice(contributions, func(i, j int) bool {
+		a, b := contributions[i], contributions[j]
+		if a.Series != b.Series {
+			return a.Series > b.Series
+		}
+		if a.DistinctValues != b.DistinctValues {
+			return a.DistinctValues > b.DistinctValues
+		}
+		return a.Name < b.Name
+	})
+
+	if len(contributions) > n {
+		contributions = contributions[:n]
+	}
+	return contributions
+}
@@ -0,0 +1,75 @@
+package emf
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPriceTableMonthlyCost(t *testing.T) {
+	tests := []struct {
+		metrics  int
+		expected float64
+	}{
+		{0, 0},
+		{100, 30},
+		{10000, 3000},
+		{20000, 4000},
+		{1500000, 3000 + 24000 + 37500 + 10000},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultPriceTable.MonthlyCost(tt.metrics); math.Abs(got-tt.expected) > 1e-6 {
+			t.Errorf("Expected %v for %d metrics, got %v", tt.expected, tt.metrics, got)
+		}
+	}
+}
+
+func TestCostEstimator(t *testing.T) {
+	estimator := NewCostEstimator(CostEstimatorConfig{
+		Prices:        PriceTable{{PricePerMetric: 1}},
+		TopDimensions: 1,
+	})
+
+	for i := 0; i < 3; i++ {
+		for _, service := range []string{"API", "Worker"} {
+			ml := newRequestLog(service, 10)
+			ml.PutDimension("Host", fmt.Sprintf("host-%d", i))
+			ml.WithDimensionSet([]string{"Service", "Host"})
+			estimator.Add(ml)
+		}
+	}
+
+	other := NewMetricLog("Other")
+	other.PutDimension("Service", "API")
+	other.WithDimensionSet([]string{"Service"})
+	other.PutMetric("Errors", 1, UnitCount)
+	if err := estimator.AddJSON([]byte(other.String())); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := estimator.AddJSON([]byte(`{"not": "emf"}`)); err == nil {
+		t.Error("Expected an error for a non-EMF event")
+	}
+
+	estimate := estimator.Estimate()
+	// TestNamespace: 2 metrics x (2 services + 2 services x 3 hosts) series.
+	if estimate.Events != 7 || estimate.Series != 17 || estimate.MonthlyCost != 17 {
+		t.Fatalf("Unexpected estimate: %+v", estimate)
+	}
+	if len(estimate.Namespaces) != 2 {
+		t.Fatalf("Expected 2 namespaces, got %d", len(estimate.Namespaces))
+	}
+
+	first := estimate.Namespaces[0]
+	if first.Namespace != "TestNamespace" || first.Series != 16 || first.Metrics != 2 || first.MonthlyCost != 16 {
+		t.Errorf("Unexpected namespace estimate: %+v", first)
+	}
+	if len(first.TopDimensions) != 1 || first.TopDimensions[0] != (DimensionContribution{Name: "Service", Series: 16, DistinctValues: 2}) {
+		t.Errorf("Unexpected top dimensions: %+v", first.TopDimensions)
+	}
+
+	estimator.Reset()
+	if estimate := estimator.Estimate(); estimate.Events != 0 || estimate.Series != 0 {
+		t.Errorf("Expected an empty estimate after a reset, got %+v", estimate)
+	}
+}
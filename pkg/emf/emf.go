@@ -12,6 +12,17 @@ import (
 type MetricLog struct {
 	emf     EmfFormatJson
 	metrics map[string]interface{}
+
+	// defaultDimensions lists the keys of the default dimensions in the order they were added.
+	defaultDimensions []string
+	// replaceDefaults is set by SetDimensions to opt out of the default dimensions.
+	replaceDefaults bool
+
+	// policy and onDrop control validation in MarshalJSON.
+	policy ValidationPolicy
+	onDrop func(Drop)
+	// preserveOrder keeps dimension sets and metric definitions in declaration order.
+	preserveOrder bool
 }
 
 // NewMetricLog creates a new EMF metric log with the given namespace.
@@ -50,6 +61,42 @@ func (ml *MetricLog) WithDimensionSet(dimensions []string) *MetricLog {
 	return ml
 }
 
+// SetDimensions replaces all dimension sets of the log, including the default dimensions,
+// with the given dimension sets.
+func (ml *MetricLog) SetDimensions(dimensionSets ...[]string) *MetricLog {
+	metricDirective := &ml.emf.Aws.CloudWatchMetrics[0]
+	metricDirective.Dimensions = append([][]string{}, dimensionSets...)
+	ml.replaceDefaults = true
+	return ml
+}
+
+// PutDefaultDimension adds a default dimension to the log. Default dimensions are
+// prepended to every dimension set, or form the only dimension set if none is defined.
+// A value set with PutDimension takes precedence over the default value.
+func (ml *MetricLog) PutDefaultDimension(key, value string) *MetricLog {
+	for _, existing := range ml.defaultDimensions {
+		if existing == key {
+			return ml
+		}
+	}
+	ml.defaultDimensions = append(ml.defaultDimensions, key)
+
+	if _, exists := ml.metrics[key]; !exists {
+		ml.metrics[key] = value
+	}
+	return ml
+}
+
+// removeDefaultDimension removes key from the default dimensions. The value is kept.
+func (ml *MetricLog) removeDefaultDimension(key string) {
+	for i, existing := range ml.defaultDimensions {
+		if existing == key {
+			ml.defaultDimensions = append(ml.defaultDimensions[:i:i], ml.defaultDimensions[i+1:]...)
+			return
+		}
+	}
+}
+
 // PutDimension adds a dimension key-value pair to the log.
 func (ml *MetricLog) PutDimension(key, value string) *MetricLog {
 	ml.metrics[key] = value
@@ -90,11 +137,102 @@ func (ml *MetricLog) PutMetricWithResolution(name string, value interface{}, uni
 	return ml
 }
 
+// Timestamp returns the timestamp of the metric log.
+func (ml *MetricLog) Timestamp() time.Time {
+	return time.UnixMilli(int64(ml.emf.Aws.Timestamp))
+}
+
+// SetTimestamp sets the timestamp of the metric log, which defaults to its creation time.
+func (ml *MetricLog) SetTimestamp(t time.Time) *MetricLog {
+	ml.emf.Aws.Timestamp = int(t.UnixMilli())
+	return ml
+}
+
+// Metadata returns a copy of the _aws metadata of the log as it will be serialized,
+// with the default dimensions applied to its dimension sets.
+func (ml *MetricLog) Metadata() EmfFormatJsonAws {
+	aws := ml.resolved().emf.Aws
+
+	directives := make([]EmfFormatJsonAwsCloudWatchMetricsElem, len(aws.CloudWatchMetrics))
+	for i, directive := range aws.CloudWatchMetrics {
+		dimensionSets := make([][]string, len(directive.Dimensions))
+		for j, dimSet := range directive.Dimensions {
+			dimensionSets[j] = append([]string(nil), dimSet...)
+		}
+		directive.Dimensions = dimensionSets
+		directive.Metrics = append([]EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem(nil), directive.Metrics...)
+		directives[i] = directive
+	}
+	aws.CloudWatchMetrics = directives
+
+	return aws
+}
+
+// Value returns the value of the metric, dimension or property with the given key.
+func (ml *MetricLog) Value(key string) (interface{}, bool) {
+	value, exists := ml.metrics[key]
+	return value, exists
+}
+
+// withDefaults returns the metric log with the default dimensions applied to its
+// dimension sets. The returned log shares its values with ml.
+func (ml *MetricLog) withDefaults() *MetricLog {
+	if len(ml.defaultDimensions) == 0 || ml.replaceDefaults {
+		return ml
+	}
+
+	resolved := *ml
+	resolved.emf.Aws.CloudWatchMetrics = append([]EmfFormatJsonAwsCloudWatchMetricsElem(nil), ml.emf.Aws.CloudWatchMetrics...)
+	directive := &resolved.emf.Aws.CloudWatchMetrics[0]
+
+	if len(directive.Dimensions) == 0 {
+		directive.Dimensions = [][]string{append([]string(nil), ml.defaultDimensions...)}
+		return &resolved
+	}
+
+	dimensionSets := make([][]string, 0, len(directive.Dimensions))
+	for _, dimSet := range directive.Dimensions {
+		merged := append([]string(nil), ml.defaultDimensions...)
+		for _, dim := range dimSet {
+			isDefault := false
+			for _, defaultDim := range ml.defaultDimensions {
+				if dim == defaultDim {
+					isDefault = true
+					break
+				}
+			}
+			if !isDefault {
+				merged = append(merged, dim)
+			}
+		}
+		dimensionSets = append(dimensionSets, merged)
+	}
+	directive.Dimensions = dimensionSets
+
+	return &resolved
+}
+
 // MarshalJSON implements the json.Marshaler interface.
+// The log is validated according to its validation policy.
 func (ml *MetricLog) MarshalJSON() ([]byte, error) {
-	// First, validate the metric log
-	if err := ml.Validate(); err != nil {
-		return nil, err
+	switch ml.policy {
+	case ValidationLenient:
+		// Drop what is invalid; the returned log has the default dimensions applied
+		resolved, err := ml.dropInvalid()
+		if err != nil {
+			return nil, err
+		}
+		ml = resolved
+	case ValidationOff:
+		ml = ml.resolved()
+	default:
+		// First, validate the metric log
+		if err := ml.Validate(); err != nil {
+			return nil, err
+		}
+
+		// Apply the default dimensions to the dimension sets
+		ml = ml.resolved()
 	}
 
 	// Create a map that combines both the EMF format and metrics
@@ -108,7 +246,11 @@ func (ml *MetricLog) MarshalJSON() ([]byte, error) {
 		combinedMap[k] = v
 	}
 
-	return json.Marshal(combinedMap)
+	data, err := json.Marshal(combinedMap)
+	if err != nil || len(data) <= MaxEventSize {
+		return data, err
+	}
+	return limitEvent(combinedMap, data, ml.emf.Aws)
 }
 
 // String returns the JSON string representation of the metric log.
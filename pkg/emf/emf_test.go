@@ -245,3 +245,38 @@ func TestEndToEnd(t *testing.T) {
 		t.Errorf("Expected RequestId:req-123, got %v", val)
 	}
 }
+
+func TestAccessors(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.emf.Aws.Timestamp = 1600000000000
+	ml.PutDefaultDimension("ServiceName", "UserService")
+	ml.PutDimension("Operation", "GetUser")
+	ml.WithDimensionSet([]string{"Operation"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+	if !ml.Timestamp().Equal(time.UnixMilli(1600000000000)) {
+		t.Errorf("Expected timestamp 1600000000000, got %v", ml.Timestamp())
+	}
+
+	metadata := ml.Metadata()
+	directive := metadata.CloudWatchMetrics[0]
+	if directive.Namespace != "TestNamespace" {
+		t.Errorf("Expected namespace TestNamespace, got %s", directive.Namespace)
+	}
+	if len(directive.Dimensions) != 1 || len(directive.Dimensions[0]) != 2 || directive.Dimensions[0][0] != "ServiceName" {
+		t.Errorf("Expected default dimensions to be applied, got %v", directive.Dimensions)
+	}
+
+	// The returned metadata is a copy.
+	directive.Dimensions[0][0] = "Changed"
+	if ml.Metadata().CloudWatchMetrics[0].Dimensions[0][0] != "ServiceName" {
+		t.Error("Expected modifications of the metadata not to affect the log")
+	}
+
+	if value, ok := ml.Value("Latency"); !ok || value != 42.0 {
+		t.Errorf("Expected Latency:42.0, got %v", value)
+	}
+	if _, ok := ml.Value("Missing"); ok {
+		t.Error("Expected no value for a missing key")
+	}
+}
@@ -0,0 +1,264 @@
+// Package emfcatalog defines metric catalogs: YAML or JSON files declaring the
+// namespace, metrics, units and dimensions a service may emit.
+//
+// A Catalog validates metric logs at runtime, and Generate turns it into typed Go
+// helpers so that metric names and units are checked at compile time:
+//
+//	namespace: Checkout
+//	dimensions: [ServiceName, Operation]
+//	dimensionSets:
+//	  - [ServiceName]
+//	  - [ServiceName, Operation]
+//	metrics:
+//	  - name: Latency
+//	    unit: Milliseconds
+//	  - name: Errors
+//	    unit: Count
+//	    storageResolution: 1
+package emfcatalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// Catalog declares the metrics and dimensions of a namespace.
+type Catalog struct {
+	// Namespace is the CloudWatch namespace of the metrics.
+	Namespace string `yaml:"namespace" json:"namespace"`
+	// Dimensions lists the allowed dimensions. Defaults to the dimensions of DimensionSets.
+	Dimensions []string `yaml:"dimensions,omitempty" json:"dimensions,omitempty"`
+	// DimensionSets lists the allowed dimension sets. If empty, any set of allowed
+	// dimensions is accepted.
+	DimensionSets [][]string `yaml:"dimensionSets,omitempty" json:"dimensionSets,omitempty"`
+	// Metrics lists the allowed metrics.
+	Metrics []Metric `yaml:"metrics" json:"metrics"`
+}
+
+// Metric declares a metric of a catalog.
+type Metric struct {
+	Name string `yaml:"name" json:"name"`
+	// Unit is the unit of the metric. Defaults to emf.UnitNone.
+	Unit string `yaml:"unit,omitempty" json:"unit,omitempty"`
+	// StorageResolution is the storage resolution of the metric. Defaults to emf.StorageResolutionStandard.
+	StorageResolution int    `yaml:"storageResolution,omitempty" json:"storageResolution,omitempty"`
+	Description       string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// Load reads and parses a catalog file.
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses a YAML or JSON catalog, applies its defaults and validates it.
+func Parse(data []byte) (*Catalog, error) {
+	var c Catalog
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+
+	if len(c.Dimensions) == 0 {
+		seen := make(map[string]bool)
+		for _, dimSet := range c.DimensionSets {
+			for _, dim := range dimSet {
+				if !seen[dim] {
+					seen[dim] = true
+					c.Dimensions = append(c.Dimensions, dim)
+				}
+			}
+		}
+	}
+	for i := range c.Metrics {
+		if c.Metrics[i].Unit == "" {
+			c.Metrics[i].Unit = emf.UnitNone
+		}
+		if c.Metrics[i].StorageResolution == 0 {
+			c.Metrics[i].StorageResolution = emf.StorageResolutionStandard
+		}
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// validate checks that the catalog itself is consistent.
+func (c *Catalog) validate() error {
+	var errs []error
+	if len(c.Namespace) < emf.MinNamespaceLength || len(c.Namespace) > emf.MaxNamespaceLength {
+		errs = append(errs, fmt.Errorf("namespace length must be between %d and %d characters",
+			emf.MinNamespaceLength, emf.MaxNamespaceLength))
+	}
+	if len(c.Metrics) == 0 {
+		errs = append(errs, errors.New("at least one metric must be declared"))
+	}
+
+	metrics := make(map[string]bool)
+	for _, m := range c.Metrics {
+		if len(m.Name) < emf.MinMetricNameLength || len(m.Name) > emf.MaxMetricNameLength {
+			errs = append(errs, fmt.Errorf("metric name '%s' length must be between %d and %d characters",
+				m.Name, emf.MinMetricNameLength, emf.MaxMetricNameLength))
+		}
+		if metrics[m.Name] {
+			errs = append(errs, fmt.Errorf("metric '%s' is declared more than once", m.Name))
+		}
+		metrics[m.Name] = true
+		if !emf.IsValidUnit(m.Unit) {
+			errs = append(errs, fmt.Errorf("invalid unit '%s' for metric '%s'", m.Unit, m.Name))
+		}
+		if m.StorageResolution != emf.StorageResolutionStandard && m.StorageResolution != emf.StorageResolutionHigh {
+			errs = append(errs, fmt.Errorf("invalid storage resolution %d for metric '%s'", m.StorageResolution, m.Name))
+		}
+	}
+
+	dimensions := make(map[string]bool)
+	for _, dim := range c.Dimensions {
+		if dim == "" || len(dim) > emf.MaxDimensionNameLength {
+			errs = append(errs, fmt.Errorf("dimension name '%s' length must be between 1 and %d characters",
+				dim, emf.MaxDimensionNameLength))
+		}
+		if dimensions[dim] {
+			errs = append(errs, fmt.Errorf("dimension '%s' is declared more than once", dim))
+		}
+		if metrics[dim] {
+			errs = append(errs, fmt.Errorf("'%s' is declared as both a metric and a dimension", dim))
+		}
+		dimensions[dim] = true
+	}
+	for i, dimSet := range c.DimensionSets {
+		if len(dimSet) == 0 || len(dimSet) > emf.MaxDimensionSetSize {
+			errs = append(errs, fmt.Errorf("dimension set %d must contain between 1 and %d dimensions", i, emf.MaxDimensionSetSize))
+		}
+		for _, dim := range dimSet {
+			if !dimensions[dim] {
+				errs = append(errs, fmt.Errorf("dimension set %d references undeclared dimension '%s'", i, dim))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Metric returns the declared metric with the given name.
+func (c *Catalog) Metric(name string) (Metric, bool) {
+	for _, m := range c.Metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// Check reports every way in which the metric log does not match the catalog: a
+// different namespace, undeclared metrics, units or storage resolutions that
+// differ from the declaration, and undeclared dimensions or dimension sets.
+// Dimension sets match regardless of the order of their dimensions.
+func (c *Catalog) Check(ml *emf.MetricLog) error {
+	return c.check(ml.Metadata())
+}
+
+// CheckJSON parses an EMF document and checks it against the catalog.
+func (c *Catalog) CheckJSON(data []byte) error {
+	ml, err := emf.ParseMetricLog(data)
+	if err != nil {
+		return err
+	}
+	return c.Check(ml)
+}
+
+// check checks the metadata of a metric log against the catalog.
+func (c *Catalog) check(aws emf.EmfFormatJsonAws) error {
+	dimensions := make(map[string]bool)
+	for _, dim := range c.Dimensions {
+		dimensions[dim] = true
+	}
+	dimensionSets := make(map[string]bool)
+	for _, dimSet := range c.DimensionSets {
+		dimensionSets[setKey(dimSet)] = true
+	}
+
+	var errs []error
+	for _, directive := range aws.CloudWatchMetrics {
+		if directive.Namespace != c.Namespace {
+			errs = append(errs, fmt.Errorf("namespace '%s' does not match the catalog namespace '%s'", directive.Namespace, c.Namespace))
+		}
+
+		for _, m := range directive.Metrics {
+			declared, ok := c.Metric(m.Name)
+			if !ok {
+				errs = append(errs, fmt.Errorf("metric '%s' is not declared in the catalog", m.Name))
+				continue
+			}
+			unit := emf.UnitNone
+			if m.Unit != nil {
+				unit = *m.Unit
+			}
+			if unit != declared.Unit {
+				errs = append(errs, fmt.Errorf("metric '%s' has unit '%s', the catalog declares '%s'", m.Name, unit, declared.Unit))
+			}
+			resolution := emf.StorageResolutionStandard
+			if m.StorageResolution != nil {
+				resolution = *m.StorageResolution
+			}
+			if resolution != declared.StorageResolution {
+				errs = append(errs, fmt.Errorf("metric '%s' has storage resolution %d, the catalog declares %d",
+					m.Name, resolution, declared.StorageResolution))
+			}
+		}
+
+		for _, dimSet := range directive.Dimensions {
+			for _, dim := range dimSet {
+				if !dimensions[dim] {
+					errs = append(errs, fmt.Errorf("dimension '%s' is not declared in the catalog", dim))
+				}
+			}
+			if len(dimensionSets) > 0 && !dimensionSets[setKey(dimSet)] {
+				errs = append(errs, fmt.Errorf("dimension set [%s] is not declared in the catalog", strings.Join(dimSet, ", ")))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setKey returns a key identifying a dimension set regardless of its order.
+func setKey(dimSet []string) string {
+	sorted := append([]string(nil), dimSet...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+// Sink wraps a sink and drops metric logs that do not match the catalog.
+type Sink struct {
+	catalog *Catalog
+	next    emf.Sink
+}
+
+// NewSink creates a Sink that emits the metric logs matching the catalog to next
+// and returns the mismatches as errors for the others.
+func NewSink(catalog *Catalog, next emf.Sink) *Sink {
+	return &Sink{catalog: catalog, next: next}
+}
+
+// Emit checks the metric log against the catalog and emits it if it matches.
+func (s *Sink) Emit(ml *emf.MetricLog) error {
+	if err := s.catalog.Check(ml); err != nil {
+		return fmt.Errorf("metric log does not match the catalog: %w", err)
+	}
+	return s.next.Emit(ml)
+}
@@ -0,0 +1,147 @@
+package emfcatalog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/emftest"
+)
+
+const catalogYAML = `
+namespace: Checkout
+dimensionSets:
+  - [ServiceName]
+  - [ServiceName, Operation]
+metrics:
+  - name: Latency
+    unit: Milliseconds
+  - name: Errors
+    unit: Count
+    storageResolution: 1
+  - name: QueueDepth
+`
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(catalogYAML))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(c.Dimensions, ",") != "ServiceName,Operation" {
+		t.Errorf("Expected the dimensions of the dimension sets, got %v", c.Dimensions)
+	}
+	if m, _ := c.Metric("QueueDepth"); m.Unit != emf.UnitNone || m.StorageResolution != emf.StorageResolutionStandard {
+		t.Errorf("Expected the default unit and resolution, got %+v", m)
+	}
+
+	// JSON is accepted as well
+	c, err = Parse([]byte(`{"namespace": "Checkout", "dimensions": ["ServiceName"], "metrics": [{"name": "Latency", "unit": "Milliseconds"}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(c.Metrics) != 1 || c.Metrics[0].Unit != emf.UnitMilliseconds {
+		t.Errorf("Expected the JSON catalog to be parsed, got %+v", c)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog string
+		err     string
+	}{
+		{"unknown field", "namespace: A\nmetric: []", "field metric not found"},
+		{"no namespace", "metrics: [{name: A}]", "namespace length"},
+		{"no metrics", "namespace: A", "at least one metric"},
+		{"invalid unit", "namespace: A\nmetrics: [{name: A, unit: Hours}]", "invalid unit 'Hours'"},
+		{"invalid resolution", "namespace: A\nmetrics: [{name: A, storageResolution: 5}]", "invalid storage resolution 5"},
+		{"duplicate metric", "namespace: A\nmetrics: [{name: A}, {name: A}]", "declared more than once"},
+		{"undeclared dimension", "namespace: A\ndimensions: [B]\ndimensionSets: [[C]]\nmetrics: [{name: A}]", "undeclared dimension 'C'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.catalog))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c, err := Parse([]byte(catalogYAML))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	valid := emf.NewMetricLog("Checkout").
+		PutDimension("ServiceName", "cart").
+		PutDimension("Operation", "Add").
+		WithDimensionSet([]string{"Operation", "ServiceName"}).
+		PutMetric("Latency", 12, emf.UnitMilliseconds).
+		PutMetricWithResolution("Errors", 1, emf.UnitCount, emf.StorageResolutionHigh).
+		PutMetric("QueueDepth", 3, emf.UnitNone)
+	if err := c.Check(valid); err != nil {
+		t.Errorf("Expected the log to match the catalog, got: %v", err)
+	}
+
+	invalid := emf.NewMetricLog("Payments").
+		PutDimension("Region", "eu-west-1").
+		WithDimensionSet([]string{"Region"}).
+		PutMetric("Latency", 12, emf.UnitSeconds).
+		PutMetric("Errors", 1, emf.UnitCount).
+		PutMetric("Retries", 1, emf.UnitCount)
+	err = c.Check(invalid)
+	for _, expected := range []string{
+		"namespace 'Payments'",
+		"metric 'Latency' has unit 'Seconds'",
+		"metric 'Errors' has storage resolution 60",
+		"metric 'Retries' is not declared",
+		"dimension 'Region' is not declared",
+		"dimension set [Region] is not declared",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error containing %q, got %v", expected, err)
+		}
+	}
+
+	data, _ := valid.MarshalJSON()
+	if err := c.CheckJSON(data); err != nil {
+		t.Errorf("Expected the document to match the catalog, got: %v", err)
+	}
+}
+
+func TestSink(t *testing.T) {
+	c, err := Parse([]byte(catalogYAML))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rec := emftest.NewRecorder()
+	sink := NewSink(c, rec)
+
+	ml := emf.NewMetricLog("Checkout").
+		PutDimension("ServiceName", "cart").
+		WithDimensionSet([]string{"ServiceName"})
+	if err := sink.Emit(ml.PutMetric("Latency", 12, emf.UnitMilliseconds)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := sink.Emit(ml.PutMetric("Retries", 1, emf.UnitCount)); err == nil {
+		t.Error("Expected the undeclared metric to be rejected")
+	}
+	if len(rec.Logs()) != 1 {
+		t.Errorf("Expected only the matching log to be emitted, got %d", len(rec.Logs()))
+	}
+
+	if err := NewSink(c, failingSink{}).Emit(emf.NewMetricLog("Checkout")); err == nil {
+		t.Error("Expected the error of the wrapped sink")
+	}
+}
+
+// failingSink fails every emit.
+type failingSink struct{}
+
+func (failingSink) Emit(*emf.MetricLog) error {
+	return errors.New("emit failed")
+}
@@ -0,0 +1,160 @@
+package emfcatalog
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// generated is the template of the Go helpers generated from a catalog.
+var generated = template.Must(template.New("catalog").Parse(`// Code generated by emf-catalog. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/zlatkoc/go-aws-emf/pkg/emf"
+
+// Namespace is the CloudWatch namespace of the catalog.
+const Namespace = {{printf "%q" .Catalog.Namespace}}
+
+// Metric names
+const (
+{{- range .Metrics}}
+	Metric{{.Ident}} = {{printf "%q" .Name}}
+{{- end}}
+)
+{{if .Dimensions}}
+// Dimension names
+const (
+{{- range .Dimensions}}
+	Dimension{{.Ident}} = {{printf "%q" .Name}}
+{{- end}}
+)
+{{end}}
+// Metrics is a metric log limited to the metrics and dimensions of the catalog.
+type Metrics struct {
+	ml *emf.MetricLog
+}
+
+// New creates a metric log in Namespace with the dimension sets of the catalog.
+func New() *Metrics {
+	return Wrap(emf.NewMetricLog(Namespace))
+}
+
+// Wrap adds the dimension sets of the catalog to a metric log, such as one
+// created by an emf.Logger.
+func Wrap(ml *emf.MetricLog) *Metrics {
+{{- range .DimensionSets}}
+	ml.WithDimensionSet([]string{ {{- range $i, $d := .}}{{if $i}}, {{end}}Dimension{{$d}}{{end -}} })
+{{- end}}
+	return &Metrics{ml: ml}
+}
+
+// MetricLog returns the underlying metric log.
+func (m *Metrics) MetricLog() *emf.MetricLog {
+	return m.ml
+}
+{{range .Dimensions}}
+// With{{.Ident}} sets the {{.Name}} dimension.
+func (m *Metrics) With{{.Ident}}(value string) *Metrics {
+	m.ml.PutDimension(Dimension{{.Ident}}, value)
+	return m
+}
+{{end}}
+{{- range .Metrics}}
+// Put{{.Ident}} records the {{.Name}} metric in {{.Unit}}.{{if .Description}} {{.Description}}{{end}}
+func (m *Metrics) Put{{.Ident}}(value interface{}) *Metrics {
+	m.ml.PutMetricWithResolution(Metric{{.Ident}}, value, {{printf "%q" .Unit}}, {{.StorageResolution}})
+	return m
+}
+{{end}}`))
+
+// generatedName pairs a catalog name with its Go identifier.
+type generatedName struct {
+	Metric
+	Ident string
+}
+
+// Generate returns Go source code for the catalog in the given package. It
+// declares constants for the namespace, metric and dimension names, and a Metrics
+// type wrapping emf.MetricLog with a With method per dimension and a Put method
+// per metric that records it with its declared unit and storage resolution.
+func Generate(c *Catalog, pkg string) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name '%s'", pkg)
+	}
+
+	data := struct {
+		Package       string
+		Catalog       *Catalog
+		Metrics       []generatedName
+		Dimensions    []generatedName
+		DimensionSets [][]string
+	}{Package: pkg, Catalog: c}
+
+	idents := make(map[string]string)
+	identOf := func(kind, name string) (string, error) {
+		ident := goIdent(name)
+		if other, exists := idents[kind+ident]; exists {
+			return "", fmt.Errorf("%s names '%s' and '%s' both map to the identifier %s", strings.ToLower(kind), other, name, ident)
+		}
+		idents[kind+ident] = name
+		return ident, nil
+	}
+
+	for _, m := range c.Metrics {
+		ident, err := identOf("Metric", m.Name)
+		if err != nil {
+			return nil, err
+		}
+		m.Description = strings.Join(strings.Fields(m.Description), " ")
+		data.Metrics = append(data.Metrics, generatedName{Metric: m, Ident: ident})
+	}
+	dimensionIdents := make(map[string]string)
+	for _, dim := range c.Dimensions {
+		ident, err := identOf("Dimension", dim)
+		if err != nil {
+			return nil, err
+		}
+		dimensionIdents[dim] = ident
+		data.Dimensions = append(data.Dimensions, generatedName{Metric: Metric{Name: dim}, Ident: ident})
+	}
+	for _, dimSet := range c.DimensionSets {
+		set := make([]string, len(dimSet))
+		for i, dim := range dimSet {
+			set[i] = dimensionIdents[dim]
+		}
+		data.DimensionSets = append(data.DimensionSets, set)
+	}
+
+	var buf bytes.Buffer
+	if err := generated.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// goIdent converts a metric or dimension name to the exported Go identifier
+// suffix, e.g. "http.request_count" to "HttpRequestCount".
+func goIdent(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
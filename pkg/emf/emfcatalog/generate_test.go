@@ -0,0 +1,53 @@
+package emfcatalog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	c, err := Parse([]byte(catalogYAML + `  - name: http.request_count
+    unit: Count
+    description: |
+      Requests handled,
+      including failures.
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	source, err := Generate(c, "checkout")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	code := string(source)
+	for _, expected := range []string{
+		"// Code generated by emf-catalog. DO NOT EDIT.",
+		"package checkout",
+		`const Namespace = "Checkout"`,
+		`MetricHttpRequestCount = "http.request_count"`,
+		`DimensionServiceName = "ServiceName"`,
+		"ml.WithDimensionSet([]string{DimensionServiceName, DimensionOperation})",
+		"func (m *Metrics) WithOperation(value string) *Metrics {",
+		"// PutHttpRequestCount records the http.request_count metric in Count. Requests handled, including failures.",
+		`m.ml.PutMetricWithResolution(MetricErrors, value, "Count", 1)`,
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("Expected generated code to contain %q, got:\n%s", expected, code)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	c, err := Parse([]byte("namespace: A\nmetrics: [{name: request_count}, {name: RequestCount}]"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := Generate(c, "metrics"); err == nil || !strings.Contains(err.Error(), "both map to the identifier RequestCount") {
+		t.Errorf("Expected an identifier collision error, got %v", err)
+	}
+	if _, err := Generate(c, "my-metrics"); err == nil {
+		t.Error("Expected an invalid package name error")
+	}
+}
@@ -0,0 +1,280 @@
+package emfotel
+
+import (
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// serviceNameKey is the resource attribute used as the default namespace.
+const serviceNameKey = attribute.Key("service.name")
+
+// units maps UCUM units used by OpenTelemetry to CloudWatch units. Other units,
+// including the dimensionless "1", map to UnitNone.
+var units = map[string]string{
+	"s":           emf.UnitSeconds,
+	"ms":          emf.UnitMilliseconds,
+	"us":          emf.UnitMicroseconds,
+	"By":          emf.UnitBytes,
+	"kBy":         emf.UnitKilobytes,
+	"MBy":         emf.UnitMegabytes,
+	"GBy":         emf.UnitGigabytes,
+	"TBy":         emf.UnitTerabytes,
+	"bit":         emf.UnitBits,
+	"kbit":        emf.UnitKilobits,
+	"Mbit":        emf.UnitMegabits,
+	"Gbit":        emf.UnitGigabits,
+	"Tbit":        emf.UnitTerabits,
+	"%":           emf.UnitPercent,
+	"By/s":        emf.UnitBytesPerSecond,
+	"kBy/s":       emf.UnitKBPerSecond,
+	"MBy/s":       emf.UnitMBPerSecond,
+	"GBy/s":       emf.UnitGBPerSecond,
+	"TBy/s":       emf.UnitTBPerSecond,
+	"bit/s":       emf.UnitBitsPerSecond,
+	"kbit/s":      emf.UnitKbitsPerSecond,
+	"Mbit/s":      emf.UnitMbitsPerSecond,
+	"Gbit/s":      emf.UnitGbitsPerSecond,
+	"Tbit/s":      emf.UnitTbitsPerSecond,
+	"{count}":     emf.UnitCount,
+	"{count}/s":   emf.UnitCountPerSecond,
+	"{request}":   emf.UnitCount,
+	"{request}/s": emf.UnitCountPerSecond,
+}
+
+// unitOf returns the CloudWatch unit of an OpenTelemetry unit. Annotations such
+// as "{request}" denote counts.
+func unitOf(unit string) string {
+	if mapped, exists := units[unit]; exists {
+		return mapped
+	}
+	if len(unit) > 2 && unit[0] == '{' {
+		if unit[len(unit)-1] == '}' {
+			return emf.UnitCount
+		}
+		if len(unit) > 4 && unit[len(unit)-3:] == "}/s" {
+			return emf.UnitCountPerSecond
+		}
+	}
+	return emf.UnitNone
+}
+
+// logKey identifies the metric log of an instrumentation scope and attribute set.
+// Parts of a split distribution after the first go to logs of their own.
+type logKey struct {
+	scope      string
+	attributes attribute.Distinct
+	part       int
+}
+
+// converter collects the data points of a ResourceMetrics into metric logs.
+type converter struct {
+	namespace  string
+	properties map[string]interface{}
+	logs       []*emf.MetricLog
+	byKey      map[logKey]*emf.MetricLog
+}
+
+// convert converts the metrics to metric logs, one per instrumentation scope and attribute set.
+func convert(rm *metricdata.ResourceMetrics, namespace string) []*emf.MetricLog {
+	c := &converter{
+		namespace:  namespace,
+		properties: make(map[string]interface{}),
+		byKey:      make(map[logKey]*emf.MetricLog),
+	}
+
+	for iter := rm.Resource.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		c.properties[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if c.namespace == "" {
+		if serviceName, exists := rm.Resource.Set().Value(serviceNameKey); exists && serviceName.AsString() != "" {
+			c.namespace = serviceName.AsString()
+		} else {
+			c.namespace = DefaultNamespace
+		}
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			c.addMetric(sm.Scope, m)
+		}
+	}
+	return c.logs
+}
+
+// addMetric adds the data points of a metric to the metric logs.
+func (c *converter) addMetric(scope instrumentation.Scope, m metricdata.Metrics) {
+	unit := unitOf(m.Unit)
+
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		for _, dp := range data.DataPoints {
+			c.log(scope, dp.Attributes, dp.Time).PutMetric(m.Name, dp.Value, unit)
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range data.DataPoints {
+			c.log(scope, dp.Attributes, dp.Time).PutMetric(m.Name, dp.Value, unit)
+		}
+	case metricdata.Gauge[int64]:
+		for _, dp := range data.DataPoints {
+			c.log(scope, dp.Attributes, dp.Time).PutMetric(m.Name, dp.Value, unit)
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			c.log(scope, dp.Attributes, dp.Time).PutMetric(m.Name, dp.Value, unit)
+		}
+	case metricdata.Histogram[int64]:
+		addHistogram(c, scope, m.Name, unit, data.DataPoints)
+	case metricdata.Histogram[float64]:
+		addHistogram(c, scope, m.Name, unit, data.DataPoints)
+	case metricdata.ExponentialHistogram[int64]:
+		addExponentialHistogram(c, scope, m.Name, unit, data.DataPoints)
+	case metricdata.ExponentialHistogram[float64]:
+		addExponentialHistogram(c, scope, m.Name, unit, data.DataPoints)
+	}
+}
+
+// log returns the metric log for the scope and attribute set, creating it if needed.
+// The log takes the latest timestamp of its data points.
+func (c *converter) log(scope instrumentation.Scope, attrs attribute.Set, timestamp time.Time) *emf.MetricLog {
+	return c.logAt(scope, attrs, timestamp, 0)
+}
+
+// logAt returns the metric log for the scope, attribute set and part of a split
+// distribution, creating it if needed.
+func (c *converter) logAt(scope instrumentation.Scope, attrs attribute.Set, timestamp time.Time, part int) *emf.MetricLog {
+	key := logKey{scope: scope.Name, attributes: attrs.Equivalent(), part: part}
+	if ml, exists := c.byKey[key]; exists {
+		if timestamp.After(ml.Timestamp()) {
+			ml.SetTimestamp(timestamp)
+		}
+		return ml
+	}
+
+	ml := emf.NewMetricLog(c.namespace).SetTimestamp(timestamp)
+	builder := ml.Builder()
+	for key, value := range c.properties {
+		builder.Property(key, value)
+	}
+
+	var dimensions []string
+	for iter := attrs.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		ml.PutDimension(string(kv.Key), kv.Value.Emit())
+		dimensions = append(dimensions, string(kv.Key))
+	}
+	if len(dimensions) == 0 {
+		ml.PutDimension(ScopeDimension, scope.Name)
+		dimensions = []string{ScopeDimension}
+	}
+	ml.WithDimensionSet(dimensions)
+
+	c.byKey[key] = ml
+	c.logs = append(c.logs, ml)
+	return ml
+}
+
+// addHistogram adds histogram data points as distributions. Each non-empty bucket
+// contributes the midpoint of its bounds, clipped to the recorded minimum and maximum,
+// so that CloudWatch can estimate percentiles.
+func addHistogram[N int64 | float64](c *converter, scope instrumentation.Scope, name, unit string, dataPoints []metricdata.HistogramDataPoint[N]) {
+	for _, dp := range dataPoints {
+		if dp.Count == 0 {
+			continue
+		}
+		// Distributions with too many values are split across logs
+		for part, dist := range histogramValue(dp).Split() {
+			c.logAt(scope, dp.Attributes, dp.Time, part).PutMetric(name, dist, unit)
+		}
+	}
+}
+
+// histogramValue converts a histogram data point to a distribution.
+func histogramValue[N int64 | float64](dp metricdata.HistogramDataPoint[N]) emf.Distribution {
+	minimum, hasMin := dp.Min.Value()
+	maximum, hasMax := dp.Max.Value()
+	low, high := float64(minimum), float64(maximum)
+	if !hasMin {
+		low = math.Inf(-1)
+	}
+	if !hasMax {
+		high = math.Inf(1)
+	}
+
+	distribution := emf.Distribution{
+		Count: float64(dp.Count),
+		Sum:   float64(dp.Sum),
+	}
+	for i, count := range dp.BucketCounts {
+		if count == 0 {
+			continue
+		}
+
+		lower, upper := low, high
+		if i > 0 && i-1 < len(dp.Bounds) {
+			lower = math.Max(lower, dp.Bounds[i-1])
+		}
+		if i < len(dp.Bounds) {
+			upper = math.Min(upper, dp.Bounds[i])
+		}
+
+		var value float64
+		switch {
+		case math.IsInf(lower, 0) && math.IsInf(upper, 0):
+			value = distribution.Sum / distribution.Count
+		case math.IsInf(lower, 0):
+			value = upper
+		case math.IsInf(upper, 0):
+			value = lower
+		default:
+			value = (lower + upper) / 2
+		}
+
+		distribution.Values = append(distribution.Values, value)
+		distribution.Counts = append(distribution.Counts, float64(count))
+	}
+
+	if len(distribution.Values) == 0 {
+		distribution.Values = []float64{distribution.Sum / distribution.Count}
+		distribution.Counts = []float64{distribution.Count}
+	}
+
+	distribution.Min = distribution.Values[0]
+	distribution.Max = distribution.Values[len(distribution.Values)-1]
+	if hasMin {
+		distribution.Min = low
+	}
+	if hasMax {
+		distribution.Max = high
+	}
+
+	return distribution
+}
+
+// addExponentialHistogram adds exponential histogram data points as statistic sets.
+// Without a recorded minimum and maximum, both are estimated with the mean.
+func addExponentialHistogram[N int64 | float64](c *converter, scope instrumentation.Scope, name, unit string, dataPoints []metricdata.ExponentialHistogramDataPoint[N]) {
+	for _, dp := range dataPoints {
+		if dp.Count == 0 {
+			continue
+		}
+
+		stats := emf.StatisticSet{Count: float64(dp.Count), Sum: float64(dp.Sum)}
+		mean := stats.Sum / stats.Count
+		stats.Min, stats.Max = mean, mean
+		if minimum, defined := dp.Min.Value(); defined {
+			stats.Min = float64(minimum)
+		}
+		if maximum, defined := dp.Max.Value(); defined {
+			stats.Max = float64(maximum)
+		}
+
+		c.log(scope, dp.Attributes, dp.Time).PutMetric(name, stats, unit)
+	}
+}
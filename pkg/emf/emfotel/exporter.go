@@ -0,0 +1,131 @@
+// Package emfotel provides an OpenTelemetry metrics exporter that writes EMF metric logs.
+//
+// The exporter converts the sums, gauges and histograms collected by the
+// OpenTelemetry Go SDK into MetricLog events: data point attributes become
+// dimensions and resource attributes become properties.
+//
+//	exporter := emfotel.New(emfotel.Config{Namespace: "MyApp"})
+//	provider := metric.NewMeterProvider(metric.WithReader(metric.NewPeriodicReader(exporter)))
+package emfotel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// DefaultNamespace is the namespace used when neither Config.Namespace nor the
+// service.name resource attribute is set.
+const DefaultNamespace = "OpenTelemetry"
+
+// ScopeDimension is the dimension that carries the instrumentation scope name of
+// data points without attributes, since every EMF metric needs a dimension.
+const ScopeDimension = "OTelLib"
+
+// errShutdown is returned by Export after Shutdown.
+var errShutdown = errors.New("emfotel: exporter is shut down")
+
+// Config configures an Exporter.
+type Config struct {
+	// Namespace is the CloudWatch namespace of the metrics. Defaults to the value
+	// of the service.name resource attribute, or DefaultNamespace.
+	Namespace string
+	// Sink receives the metric logs. Defaults to a WriterSink on standard output.
+	Sink emf.Sink
+	// TemporalitySelector selects the temporality per instrument kind. Defaults to
+	// DeltaTemporalitySelector, since CloudWatch aggregates the values it receives.
+	TemporalitySelector metric.TemporalitySelector
+	// AggregationSelector selects the aggregation per instrument kind. Defaults to
+	// metric.DefaultAggregationSelector.
+	AggregationSelector metric.AggregationSelector
+}
+
+// Exporter is a metric.Exporter that writes EMF metric logs.
+type Exporter struct {
+	config Config
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+var _ metric.Exporter = (*Exporter)(nil)
+
+// New creates a new Exporter with the given configuration.
+func New(config Config) *Exporter {
+	if config.Sink == nil {
+		config.Sink = emf.NewWriterSink(os.Stdout)
+	}
+	if config.TemporalitySelector == nil {
+		config.TemporalitySelector = DeltaTemporalitySelector
+	}
+	if config.AggregationSelector == nil {
+		config.AggregationSelector = metric.DefaultAggregationSelector
+	}
+
+	return &Exporter{config: config}
+}
+
+// DeltaTemporalitySelector selects delta temporality for counters and histograms,
+// and cumulative temporality for up-down counters and gauges, whose current value
+// is what matters.
+func DeltaTemporalitySelector(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindUpDownCounter, metric.InstrumentKindObservableUpDownCounter,
+		metric.InstrumentKindGauge, metric.InstrumentKindObservableGauge:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+// Temporality returns the temporality to use for the instrument kind.
+func (e *Exporter) Temporality(kind metric.InstrumentKind) metricdata.Temporality {
+	return e.config.TemporalitySelector(kind)
+}
+
+// Aggregation returns the aggregation to use for the instrument kind.
+func (e *Exporter) Aggregation(kind metric.InstrumentKind) metric.Aggregation {
+	return e.config.AggregationSelector(kind)
+}
+
+// Export converts the metrics to metric logs, one per instrumentation scope and
+// attribute set, and emits them to the sink.
+func (e *Exporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	shutdown := e.shutdown
+	e.mu.Unlock()
+	if shutdown {
+		return errShutdown
+	}
+
+	var errs []error
+	for _, ml := range convert(rm, e.config.Namespace) {
+		if err := e.config.Sink.Emit(ml); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ForceFlush does nothing, since metric logs are emitted during Export.
+func (e *Exporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Shutdown shuts the exporter down. Export fails after Shutdown.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.shutdown = true
+	e.mu.Unlock()
+	return ctx.Err()
+}
@@ -0,0 +1,201 @@
+package emfotel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/emftest"
+)
+
+// setup returns a meter whose measurements are exported to a recorder by collect.
+func setup(t *testing.T, config Config) (otelmetric.Meter, *emftest.Recorder, func()) {
+	t.Helper()
+
+	rec := emftest.NewRecorder()
+	config.Sink = rec
+	exporter := New(config)
+
+	reader := metric.NewManualReader(
+		metric.WithTemporalitySelector(exporter.Temporality),
+		metric.WithAggregationSelector(exporter.Aggregation),
+	)
+	provider := metric.NewMeterProvider(
+		metric.WithReader(reader),
+		metric.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "checkout"),
+			attribute.String("deployment.environment", "prod"),
+		)),
+	)
+
+	collect := func() {
+		t.Helper()
+
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatalf("Failed to collect: %v", err)
+		}
+		if err := exporter.Export(context.Background(), &rm); err != nil {
+			t.Fatalf("Failed to export: %v", err)
+		}
+	}
+	return provider.Meter("test"), rec, collect
+}
+
+func TestExporterSumsAndGauges(t *testing.T) {
+	meter, rec, collect := setup(t, Config{})
+	ctx := context.Background()
+	attrs := otelmetric.WithAttributes(attribute.String("route", "/cart"), attribute.Int("status", 200))
+
+	requests, _ := meter.Int64Counter("requests", otelmetric.WithUnit("{request}"))
+	requests.Add(ctx, 3, attrs)
+	inFlight, _ := meter.Int64UpDownCounter("in_flight")
+	inFlight.Add(ctx, 5, attrs)
+	temperature, _ := meter.Float64Gauge("temperature")
+	temperature.Record(ctx, 21.5)
+
+	collect()
+	dims := map[string]string{"route": "/cart", "status": "200"}
+	emftest.AssertMetric(t, rec, "checkout", "requests", dims, emftest.All(emftest.Equal(3), emftest.Unit(emf.UnitCount)))
+	emftest.AssertMetric(t, rec, "checkout", "in_flight", dims, emftest.All(emftest.Equal(5), emftest.Unit(emf.UnitNone)))
+	emftest.AssertMetric(t, rec, "checkout", "temperature", map[string]string{ScopeDimension: "test"}, 21.5)
+	emftest.AssertValid(t, rec)
+
+	logs := rec.Logs()
+	if len(logs) != 2 {
+		t.Fatalf("Expected a log per attribute set, got %d", len(logs))
+	}
+	if env, _ := logs[0].Value("deployment.environment"); env != "prod" {
+		t.Errorf("Expected resource attributes as properties, got %v", env)
+	}
+
+	// Counters are exported as deltas, up-down counters as their current value.
+	rec.Reset()
+	requests.Add(ctx, 2, attrs)
+	inFlight.Add(ctx, -1, attrs)
+	collect()
+	emftest.AssertMetric(t, rec, "checkout", "requests", dims, 2)
+	emftest.AssertMetric(t, rec, "checkout", "in_flight", dims, 4)
+}
+
+func TestExporterHistograms(t *testing.T) {
+	meter, rec, collect := setup(t, Config{Namespace: "MyApp"})
+	ctx := context.Background()
+
+	latency, _ := meter.Float64Histogram("latency",
+		otelmetric.WithUnit("ms"),
+		otelmetric.WithExplicitBucketBoundaries(10, 100, 1000))
+	for _, value := range []float64{4, 6, 50, 2000} {
+		latency.Record(ctx, value)
+	}
+
+	collect()
+	emftest.AssertValid(t, rec)
+
+	metrics := emftest.FindMetrics(rec, "MyApp", "latency", nil)
+	if len(metrics) != 1 {
+		t.Fatalf("Expected 1 latency metric, got %d", len(metrics))
+	}
+	if metrics[0].Unit != emf.UnitMilliseconds {
+		t.Errorf("Expected unit %s, got %s", emf.UnitMilliseconds, metrics[0].Unit)
+	}
+
+	distribution, ok := metrics[0].Value.(emf.Distribution)
+	if !ok {
+		t.Fatalf("Expected a distribution, got %T", metrics[0].Value)
+	}
+	expected := emf.Distribution{
+		Values: []float64{7, 55, 1500},
+		Counts: []float64{2, 1, 1},
+		Min:    4,
+		Max:    2000,
+		Count:  4,
+		Sum:    2060,
+	}
+	if !reflect.DeepEqual(distribution, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, distribution)
+	}
+}
+
+func TestExporterHistogramWithManyBuckets(t *testing.T) {
+	meter, rec, collect := setup(t, Config{Namespace: "MyApp"})
+	ctx := context.Background()
+
+	boundaries := make([]float64, emf.MaxValuesPerMetric+50)
+	for i := range boundaries {
+		boundaries[i] = float64(i + 1)
+	}
+	size, _ := meter.Float64Histogram("size", otelmetric.WithExplicitBucketBoundaries(boundaries...))
+	for i := 0; i < emf.MaxValuesPerMetric+10; i++ {
+		size.Record(ctx, float64(i)+0.5)
+	}
+
+	collect()
+	emftest.AssertValid(t, rec)
+
+	metrics := emftest.FindMetrics(rec, "MyApp", "size", nil)
+	if len(metrics) != 2 {
+		t.Fatalf("Expected the histogram to be split across 2 logs, got %d", len(metrics))
+	}
+	var count float64
+	for _, metric := range metrics {
+		distribution, ok := metric.Value.(emf.Distribution)
+		if !ok || len(distribution.Values) > emf.MaxValuesPerMetric {
+			t.Fatalf("Expected distributions of at most %d values, got %#v", emf.MaxValuesPerMetric, metric.Value)
+		}
+		count += distribution.Count
+	}
+	if count != emf.MaxValuesPerMetric+10 {
+		t.Errorf("Expected %d observations, got %v", emf.MaxValuesPerMetric+10, count)
+	}
+}
+
+func TestExporterShutdown(t *testing.T) {
+	exporter := New(Config{Sink: emftest.NewRecorder()})
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := exporter.Export(context.Background(), &metricdata.ResourceMetrics{}); err == nil {
+		t.Error("Expected Export to fail after Shutdown")
+	}
+}
+
+func TestUnitOf(t *testing.T) {
+	tests := map[string]string{
+		"ms":         emf.UnitMilliseconds,
+		"By":         emf.UnitBytes,
+		"{item}":     emf.UnitCount,
+		"{packet}/s": emf.UnitCountPerSecond,
+		"1":          emf.UnitNone,
+		"ns":         emf.UnitNone,
+	}
+	for unit, expected := range tests {
+		if got := unitOf(unit); got != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, unit, got)
+		}
+	}
+}
+
+func TestDeltaTemporalitySelector(t *testing.T) {
+	tests := map[metric.InstrumentKind]metricdata.Temporality{
+		metric.InstrumentKindCounter:                 metricdata.DeltaTemporality,
+		metric.InstrumentKindObservableCounter:       metricdata.DeltaTemporality,
+		metric.InstrumentKindHistogram:               metricdata.DeltaTemporality,
+		metric.InstrumentKindUpDownCounter:           metricdata.CumulativeTemporality,
+		metric.InstrumentKindObservableUpDownCounter: metricdata.CumulativeTemporality,
+		metric.InstrumentKindGauge:                   metricdata.CumulativeTemporality,
+		metric.InstrumentKindObservableGauge:         metricdata.CumulativeTemporality,
+	}
+	for kind, expected := range tests {
+		if got := DeltaTemporalitySelector(kind); got != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, kind, got)
+		}
+	}
+}
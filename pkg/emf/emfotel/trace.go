@@ -0,0 +1,38 @@
+package emfotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// PropertySpanID is the property that holds the span ID of the current span.
+const PropertySpanID = "SpanId"
+
+// PutSpanContext adds the trace ID and span ID of the span in ctx as the TraceId and
+// SpanId properties, so that a metric spike can be followed to the trace. The log
+// is unchanged if ctx carries no valid span context.
+func PutSpanContext(ctx context.Context, ml *emf.MetricLog) *emf.MetricLog {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return ml
+	}
+	ml.PutTraceID(spanContext.TraceID().String())
+	ml.PutProperty(PropertySpanID, spanContext.SpanID().String())
+	return ml
+}
+
+// PutXRaySpanContext adds the trace ID of the span in ctx in X-Ray format as the
+// xray_trace_id property, so that CloudWatch can link the log event to the trace.
+// The log is unchanged if ctx carries no valid span context.
+func PutXRaySpanContext(ctx context.Context, ml *emf.MetricLog) *emf.MetricLog {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return ml
+	}
+	t := emf.Trace{TraceID: spanContext.TraceID().String(), Format: emf.TraceFormatW3C}
+	ml.PutProperty(emf.PropertyXRayTraceID, t.XRayTraceID())
+	return ml
+}
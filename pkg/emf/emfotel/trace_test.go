@@ -0,0 +1,45 @@
+package emfotel
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+func TestPutSpanContext(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	spanContext := span.SpanContext()
+
+	ml := emf.NewMetricLog("TestNamespace")
+	PutSpanContext(ctx, ml)
+	PutXRaySpanContext(ctx, ml)
+
+	traceID := spanContext.TraceID().String()
+	if value, _ := ml.Value(emf.PropertyTraceID); value != traceID {
+		t.Errorf("Expected TraceId %s, got %v", traceID, value)
+	}
+	if value, _ := ml.Value(PropertySpanID); value != spanContext.SpanID().String() {
+		t.Errorf("Expected SpanId %s, got %v", spanContext.SpanID(), value)
+	}
+	expected := "1-" + traceID[:8] + "-" + traceID[8:]
+	if value, _ := ml.Value(emf.PropertyXRayTraceID); value != expected {
+		t.Errorf("Expected xray_trace_id %s, got %v", expected, value)
+	}
+
+	// A context without a span leaves the log unchanged
+	ml = emf.NewMetricLog("TestNamespace")
+	PutSpanContext(context.Background(), ml)
+	PutXRaySpanContext(context.Background(), ml)
+	for _, key := range []string{emf.PropertyTraceID, PropertySpanID, emf.PropertyXRayTraceID} {
+		if value, ok := ml.Value(key); ok {
+			t.Errorf("Expected no %s without a span, got %v", key, value)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Package emfprom bridges a Prometheus registry to EMF.
+//
+// A Bridge periodically gathers a prometheus.Gatherer and emits the metrics as
+// MetricLog events: counters as the change since the previous gather, gauges as
+// their current value, summaries as quantiles plus count and sum changes, and
+// histograms as distributions of the observations since the previous gather.
+// Labels become dimensions, subject to the allow and deny lists.
+package emfprom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// DefaultNamespace is the namespace used when Config.Namespace is not set.
+const DefaultNamespace = "Prometheus"
+
+// SourceValue is the value of emf.SourceDimension, which is added to series without
+// any dimension since every EMF metric needs one.
+const SourceValue = "prometheus"
+
+// Config configures a Bridge.
+type Config struct {
+	// Namespace is the CloudWatch namespace of the metrics. Defaults to DefaultNamespace.
+	Namespace string
+	// Gatherer is the registry to gather. Defaults to prometheus.DefaultGatherer.
+	Gatherer prometheus.Gatherer
+	// Sink receives the metric logs. Defaults to a WriterSink on standard output.
+	Sink emf.Sink
+	// Interval is the time between gathers started by Start. Defaults to emf.DefaultFlushInterval.
+	Interval time.Duration
+	// AllowLabels lists the labels that become dimensions. If empty, all labels do.
+	AllowLabels []string
+	// DenyLabels lists labels that never become dimensions. Labels that are not
+	// dimensions are kept as properties, so CloudWatch aggregates over them.
+	DenyLabels []string
+	// DefaultDimensions are added to every metric log.
+	DefaultDimensions []emf.Dimension
+	// OnError is called with errors of the background gathers started by Start.
+	OnError func(error)
+}
+
+// Bridge gathers a Prometheus registry and emits its metrics as EMF.
+type Bridge struct {
+	config Config
+	allow  map[string]bool
+	deny   map[string]bool
+
+	mu       sync.Mutex
+	previous map[string]cumulative
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// cumulative holds the cumulative values of a series at the previous gather.
+type cumulative struct {
+	value   float64
+	count   float64
+	sum     float64
+	buckets []float64
+}
+
+// NewBridge creates a new Bridge with the given configuration.
+func NewBridge(config Config) *Bridge {
+	if config.Namespace == "" {
+		config.Namespace = DefaultNamespace
+	}
+	if config.Gatherer == nil {
+		config.Gatherer = prometheus.DefaultGatherer
+	}
+	if config.Sink == nil {
+		config.Sink = emf.NewWriterSink(os.Stdout)
+	}
+	if config.Interval <= 0 {
+		config.Interval = emf.DefaultFlushInterval
+	}
+
+	return &Bridge{
+		config:   config,
+		allow:    toSet(config.AllowLabels),
+		deny:     toSet(config.DenyLabels),
+		previous: make(map[string]cumulative),
+	}
+}
+
+// Collect gathers the registry and converts its metrics to metric logs, one per
+// label set. Counters, summary counts and sums, and histograms are reported as
+// the change since the previous call, so the first call only records a baseline
+// for them.
+func (b *Bridge) Collect() ([]*emf.MetricLog, error) {
+	families, gatherErr := b.config.Gatherer.Gather()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := &collection{bridge: b, current: make(map[string]cumulative), byKey: make(map[string]*emf.MetricLog)}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			c.add(family, m)
+		}
+	}
+	b.previous = c.current
+
+	return c.logs, gatherErr
+}
+
+// Flush collects the registry and emits the metric logs to the sink.
+func (b *Bridge) Flush() error {
+	logs, err := b.Collect()
+
+	errs := []error{err}
+	for _, ml := range logs {
+		errs = append(errs, b.config.Sink.Emit(ml))
+	}
+	return errors.Join(errs...)
+}
+
+// Start begins flushing the bridge every configured interval in a background goroutine.
+// The first gather happens immediately to record the baseline of the counters.
+func (b *Bridge) Start() {
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+
+	if _, err := b.Collect(); err != nil && b.config.OnError != nil {
+		b.config.OnError(err)
+	}
+
+	go func() {
+		defer close(b.done)
+
+		ticker := time.NewTicker(b.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := b.Flush(); err != nil && b.config.OnError != nil {
+					b.config.OnError(err)
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background flushing started by Start and flushes the changes since the last gather.
+func (b *Bridge) Stop() error {
+	if b.stop != nil {
+		close(b.stop)
+		<-b.done
+		b.stop = nil
+	}
+	return b.Flush()
+}
+
+// collection converts the metric families of a single gather.
+type collection struct {
+	bridge  *Bridge
+	current map[string]cumulative
+	logs    []*emf.MetricLog
+	byKey   map[string]*emf.MetricLog
+}
+
+// add converts a single metric of a family.
+func (c *collection) add(family *dto.MetricFamily, m *dto.Metric) {
+	name := family.GetName()
+	labels := m.GetLabel()
+	seriesKey := name + "\x00" + labelKey(labels)
+	previous, seen := c.bridge.previous[seriesKey]
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		value := m.GetCounter().GetValue()
+		c.current[seriesKey] = cumulative{value: value}
+		if seen {
+			c.log(labels).PutMetric(name, delta(value, previous.value), unitOf(name, emf.UnitCount))
+		}
+
+	case dto.MetricType_GAUGE:
+		c.log(labels).PutMetric(name, m.GetGauge().GetValue(), unitOf(name, emf.UnitNone))
+
+	case dto.MetricType_UNTYPED:
+		c.log(labels).PutMetric(name, m.GetUntyped().GetValue(), unitOf(name, emf.UnitNone))
+
+	case dto.MetricType_SUMMARY:
+		summary := m.GetSummary()
+		ml := c.log(labels)
+		for _, q := range summary.GetQuantile() {
+			ml.PutMetric(fmt.Sprintf("%s_p%g", name, math.Round(q.GetQuantile()*1e5)/1e3), q.GetValue(), unitOf(name, emf.UnitNone))
+		}
+
+		count, sum := float64(summary.GetSampleCount()), summary.GetSampleSum()
+		c.current[seriesKey] = cumulative{count: count, sum: sum}
+		if seen {
+			ml.PutMetric(name+"_count", delta(count, previous.count), emf.UnitCount)
+			ml.PutMetric(name+"_sum", delta(sum, previous.sum), unitOf(name, emf.UnitNone))
+		}
+
+	case dto.MetricType_HISTOGRAM:
+		histogram := m.GetHistogram()
+		current := cumulative{count: float64(histogram.GetSampleCount()), sum: histogram.GetSampleSum()}
+		for _, bucket := range histogram.GetBucket() {
+			current.buckets = append(current.buckets, float64(bucket.GetCumulativeCount()))
+		}
+		c.current[seriesKey] = current
+
+		if seen && len(previous.buckets) == len(current.buckets) {
+			if value, ok := histogramValue(histogram.GetBucket(), current, previous); ok {
+				// Distributions with too many values are split across logs
+				for part, dist := range value.Split() {
+					c.logAt(labels, part).PutMetric(name, dist, unitOf(name, emf.UnitNone))
+				}
+			}
+		}
+	}
+}
+
+// log returns the metric log for the label set, creating it if needed.
+func (c *collection) log(labels []*dto.LabelPair) *emf.MetricLog {
+	return c.logAt(labels, 0)
+}
+
+// logAt returns the metric log for the label set and a part of a split
+// distribution, creating it if needed.
+func (c *collection) logAt(labels []*dto.LabelPair, part int) *emf.MetricLog {
+	key := labelKey(labels)
+	if part > 0 {
+		key += "\x02" + strconv.Itoa(part)
+	}
+	if ml, exists := c.byKey[key]; exists {
+		return ml
+	}
+
+	config := c.bridge.config
+	ml := emf.NewMetricLog(config.Namespace)
+	for _, dim := range config.DefaultDimensions {
+		ml.PutDefaultDimension(dim.Name, dim.Value)
+	}
+
+	var dimensions []string
+	for _, label := range labels {
+		if label.GetValue() == "" {
+			continue
+		}
+		if c.bridge.isDimension(label.GetName()) {
+			ml.PutDimension(label.GetName(), label.GetValue())
+			dimensions = append(dimensions, label.GetName())
+		} else {
+			ml.Builder().Property(label.GetName(), label.GetValue())
+		}
+	}
+
+	switch {
+	case len(dimensions) > 0:
+		ml.WithDimensionSet(dimensions)
+	case len(config.DefaultDimensions) == 0:
+		ml.PutDimension(emf.SourceDimension, SourceValue)
+		ml.WithDimensionSet([]string{emf.SourceDimension})
+	}
+
+	c.byKey[key] = ml
+	c.logs = append(c.logs, ml)
+	return ml
+}
+
+// isDimension reports whether the label becomes a dimension.
+func (b *Bridge) isDimension(label string) bool {
+	if len(b.allow) > 0 && !b.allow[label] {
+		return false
+	}
+	return !b.deny[label]
+}
+
+// histogramValue converts the observations between two gathers of a histogram to a
+// distribution. Each bucket contributes the midpoint of its bounds; the bucket
+// above the highest bound contributes that bound.
+func histogramValue(buckets []*dto.Bucket, current, previous cumulative) (emf.Distribution, bool) {
+	distribution := emf.Distribution{
+		Count: delta(current.count, previous.count),
+		Sum:   delta(current.sum, previous.sum),
+	}
+	if distribution.Count <= 0 {
+		return distribution, false
+	}
+
+	below, lower, bounded := 0.0, 0.0, false
+	for i, bucket := range buckets {
+		upper := bucket.GetUpperBound()
+		if math.IsInf(upper, 1) {
+			continue
+		}
+		if !bounded && upper <= 0 {
+			lower = upper
+		}
+		bounded = true
+
+		cumulativeCount := delta(current.buckets[i], previous.buckets[i])
+		if count := cumulativeCount - below; count > 0 {
+			distribution.Values = append(distribution.Values, (lower+upper)/2)
+			distribution.Counts = append(distribution.Counts, count)
+		}
+		below, lower = cumulativeCount, upper
+	}
+	if overflow := distribution.Count - below; overflow > 0 {
+		value := lower
+		if !bounded {
+			value = distribution.Sum / distribution.Count
+		}
+		distribution.Values = append(distribution.Values, value)
+		distribution.Counts = append(distribution.Counts, overflow)
+	}
+	if len(distribution.Values) == 0 {
+		return distribution, false
+	}
+
+	distribution.Min = distribution.Values[0]
+	distribution.Max = distribution.Values[len(distribution.Values)-1]
+	return distribution, true
+}
+
+// delta returns the change of a cumulative value. A decrease means the counter
+// was reset, in which case the current value is the change.
+func delta(current, previous float64) float64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// unitOf infers the unit of a metric from the base unit suffix of its name.
+func unitOf(name, fallback string) string {
+	name = strings.TrimSuffix(name, "_total")
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return emf.UnitSeconds
+	case strings.HasSuffix(name, "_milliseconds"):
+		return emf.UnitMilliseconds
+	case strings.HasSuffix(name, "_bytes"):
+		return emf.UnitBytes
+	case strings.HasSuffix(name, "_percent"):
+		return emf.UnitPercent
+	default:
+		return fallback
+	}
+}
+
+// labelKey builds a stable key from a label set.
+func labelKey(labels []*dto.LabelPair) string {
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label.GetName() + "\x01" + label.GetValue()
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
+
+// toSet returns the values as a set.
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
@@ -0,0 +1,182 @@
+package emfprom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/emftest"
+)
+
+func newBridge(t *testing.T, config Config) (*prometheus.Registry, *emftest.Recorder, *Bridge) {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	rec := emftest.NewRecorder()
+	config.Gatherer = registry
+	config.Sink = rec
+	return registry, rec, NewBridge(config)
+}
+
+func TestBridgeCountersAndGauges(t *testing.T) {
+	registry, rec, bridge := newBridge(t, Config{Namespace: "MyApp"})
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "http_requests_total"}, []string{"route", "code"})
+	inFlight := prometheus.NewGauge(prometheus.GaugeOpts{Name: "in_flight_requests"})
+	registry.MustRegister(requests, inFlight)
+
+	requests.WithLabelValues("/cart", "200").Add(5)
+	inFlight.Set(3)
+
+	// The first flush records the counter baseline and reports the gauge.
+	if err := bridge.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	emftest.AssertNoMetric(t, rec, "MyApp", "http_requests_total", nil)
+	emftest.AssertMetric(t, rec, "MyApp", "in_flight_requests", map[string]string{emf.SourceDimension: SourceValue}, 3)
+
+	rec.Reset()
+	requests.WithLabelValues("/cart", "200").Add(2)
+	if err := bridge.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	emftest.AssertMetric(t, rec, "MyApp", "http_requests_total", map[string]string{"route": "/cart", "code": "200"},
+		emftest.All(emftest.Equal(2), emftest.Unit(emf.UnitCount)))
+	emftest.AssertValid(t, rec)
+}
+
+func TestBridgeLabelFilters(t *testing.T) {
+	registry, rec, bridge := newBridge(t, Config{
+		AllowLabels:       []string{"route", "code", "pod"},
+		DenyLabels:        []string{"pod"},
+		DefaultDimensions: []emf.Dimension{{Name: "Service", Value: "checkout"}},
+	})
+
+	latency := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "latency_seconds"}, []string{"route", "pod", "user"})
+	registry.MustRegister(latency)
+	latency.WithLabelValues("/cart", "pod-1", "alice").Set(0.5)
+
+	logs, err := bridge.Collect()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, ml := range logs {
+		rec.Emit(ml)
+	}
+
+	emftest.AssertMetric(t, rec, DefaultNamespace, "latency_seconds", map[string]string{"Service": "checkout", "route": "/cart"},
+		emftest.All(emftest.Equal(0.5), emftest.Unit(emf.UnitSeconds)))
+	if pod, _ := logs[0].Value("pod"); pod != "pod-1" {
+		t.Errorf("Expected the denied label as a property, got %v", pod)
+	}
+	if user, _ := logs[0].Value("user"); user != "alice" {
+		t.Errorf("Expected the label outside the allow list as a property, got %v", user)
+	}
+}
+
+func TestBridgeSummariesAndHistograms(t *testing.T) {
+	registry, rec, bridge := newBridge(t, Config{})
+
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "rpc_duration_seconds",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01},
+	})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "request_size_bytes",
+		Buckets: []float64{100, 1000},
+	})
+	registry.MustRegister(summary, histogram)
+
+	summary.Observe(1)
+	histogram.Observe(10)
+	if _, err := bridge.Collect(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, value := range []float64{50, 150, 250, 5000} {
+		histogram.Observe(value)
+	}
+	summary.Observe(2)
+	summary.Observe(3)
+	if err := bridge.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dims := map[string]string{emf.SourceDimension: SourceValue}
+	emftest.AssertMetric(t, rec, DefaultNamespace, "rpc_duration_seconds_p50", dims, emftest.Unit(emf.UnitSeconds))
+	emftest.AssertMetric(t, rec, DefaultNamespace, "rpc_duration_seconds_p90", dims, emftest.Any())
+	emftest.AssertMetric(t, rec, DefaultNamespace, "rpc_duration_seconds_count", dims, 2)
+	emftest.AssertMetric(t, rec, DefaultNamespace, "rpc_duration_seconds_sum", dims, 5)
+
+	expected := emf.Distribution{
+		Values: []float64{50, 550, 1000},
+		Counts: []float64{1, 2, 1},
+		Min:    50,
+		Max:    1000,
+		Count:  4,
+		Sum:    5450,
+	}
+	emftest.AssertMetric(t, rec, DefaultNamespace, "request_size_bytes", dims,
+		emftest.All(emftest.Equal(expected), emftest.Unit(emf.UnitBytes)))
+	emftest.AssertValid(t, rec)
+}
+
+func TestBridgeHistogramWithManyBuckets(t *testing.T) {
+	registry, rec, bridge := newBridge(t, Config{})
+
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "request_size_bytes",
+		Buckets: prometheus.LinearBuckets(1, 1, emf.MaxValuesPerMetric+50),
+	})
+	registry.MustRegister(histogram)
+
+	if _, err := bridge.Collect(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < emf.MaxValuesPerMetric+10; i++ {
+		histogram.Observe(float64(i) + 0.5)
+	}
+	if err := bridge.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dims := map[string]string{emf.SourceDimension: SourceValue}
+	metrics := emftest.FindMetrics(rec, DefaultNamespace, "request_size_bytes", dims)
+	if len(metrics) != 2 {
+		t.Fatalf("Expected the histogram to be split across 2 logs, got %d", len(metrics))
+	}
+	var count float64
+	for _, metric := range metrics {
+		dist, ok := metric.Value.(emf.Distribution)
+		if !ok || len(dist.Values) > emf.MaxValuesPerMetric {
+			t.Fatalf("Expected distributions of at most %d values, got %#v", emf.MaxValuesPerMetric, metric.Value)
+		}
+		count += dist.Count
+	}
+	if count != emf.MaxValuesPerMetric+10 {
+		t.Errorf("Expected %d observations, got %v", emf.MaxValuesPerMetric+10, count)
+	}
+	emftest.AssertValid(t, rec)
+}
+
+func TestBridgeCounterReset(t *testing.T) {
+	if got := delta(3, 10); got != 3 {
+		t.Errorf("Expected a reset counter to report its value, got %v", got)
+	}
+	if got := delta(10, 3); got != 7 {
+		t.Errorf("Expected the difference, got %v", got)
+	}
+}
+
+func TestBridgeGatherErrors(t *testing.T) {
+	failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("gather failed")
+	})
+	bridge := NewBridge(Config{Gatherer: failing, Sink: emftest.NewRecorder()})
+	if err := bridge.Flush(); err == nil {
+		t.Error("Expected the gather error to be returned")
+	}
+}
@@ -0,0 +1,304 @@
+// Package emfruntime periodically reports Go runtime metrics, and selected expvar
+// variables, as EMF metric logs.
+//
+// It is a separate package so that only programs that report runtime metrics
+// import expvar, which publishes /debug/vars on http.DefaultServeMux.
+package emfruntime
+
+import (
+	"errors"
+	"expvar"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// DefaultNamespace is the namespace of the runtime metrics when Config.Namespace
+// is not set.
+const DefaultNamespace = "GoRuntime"
+
+// runtimeMetric maps a runtime/metrics sample to an EMF metric.
+type runtimeMetric struct {
+	sample string
+	name   string
+	unit   string
+	// cumulative samples are reported as the change since the previous collection.
+	cumulative bool
+}
+
+// runtimeMetrics lists the runtime/metrics samples the collector reports.
+// Histograms of seconds are reported as distributions in milliseconds.
+var runtimeMetrics = []runtimeMetric{
+	{sample: "/sched/goroutines:goroutines", name: "Goroutines", unit: emf.UnitCount},
+	{sample: "/sched/gomaxprocs:threads", name: "GOMAXPROCS", unit: emf.UnitCount},
+	{sample: "/sched/latencies:seconds", name: "SchedulerLatency", unit: emf.UnitMilliseconds, cumulative: true},
+	{sample: "/memory/classes/total:bytes", name: "TotalMemory", unit: emf.UnitBytes},
+	{sample: "/memory/classes/heap/objects:bytes", name: "HeapInUse", unit: emf.UnitBytes},
+	{sample: "/gc/heap/goal:bytes", name: "HeapGoal", unit: emf.UnitBytes},
+	{sample: "/gc/heap/objects:objects", name: "HeapObjects", unit: emf.UnitCount},
+	{sample: "/gc/heap/allocs:bytes", name: "HeapAllocated", unit: emf.UnitBytes, cumulative: true},
+	{sample: "/gc/cycles/total:gc-cycles", name: "GCCycles", unit: emf.UnitCount, cumulative: true},
+	{sample: "/sched/pauses/total/gc:seconds", name: "GCPause", unit: emf.UnitMilliseconds, cumulative: true},
+}
+
+// Config configures a Collector.
+type Config struct {
+	// Namespace is the namespace of the metrics. Defaults to DefaultNamespace.
+	Namespace string
+	// Dimensions identify the service. Defaults to a ServiceName dimension with the
+	// name of the executable.
+	Dimensions []emf.Dimension
+	// ExpvarNames lists expvar variables to report in addition to the runtime metrics.
+	// Int and Float variables, Funcs returning a number, and the numeric entries of
+	// Maps (as "name.key") are reported with UnitNone.
+	ExpvarNames []string
+	// Sink receives the metric logs. Defaults to a WriterSink on standard output.
+	Sink emf.Sink
+	// Interval is the time between collections started by Start. Defaults to emf.DefaultFlushInterval.
+	Interval time.Duration
+	// OnError is called with errors of the background collections started by Start.
+	OnError func(error)
+}
+
+// Collector periodically snapshots Go runtime metrics and selected expvar
+// variables and emits them as a metric log.
+type Collector struct {
+	config  Config
+	metrics []runtimeMetric
+	samples []metrics.Sample
+
+	mu sync.Mutex
+	// previous holds the last value of each cumulative sample: a float64 for
+	// scalars and the bucket counts for histograms.
+	previous map[string]interface{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a new Collector with the given configuration.
+func New(config Config) *Collector {
+	if config.Namespace == "" {
+		config.Namespace = DefaultNamespace
+	}
+	if len(config.Dimensions) == 0 {
+		config.Dimensions = []emf.Dimension{{Name: "ServiceName", Value: filepath.Base(os.Args[0])}}
+	}
+	if config.Sink == nil {
+		config.Sink = emf.NewWriterSink(os.Stdout)
+	}
+	if config.Interval <= 0 {
+		config.Interval = emf.DefaultFlushInterval
+	}
+
+	// Skip samples the running Go version does not support
+	supported := make(map[string]bool)
+	for _, desc := range metrics.All() {
+		supported[desc.Name] = true
+	}
+
+	c := &Collector{
+		config:   config,
+		previous: make(map[string]interface{}),
+	}
+	for _, m := range runtimeMetrics {
+		if supported[m.sample] {
+			c.metrics = append(c.metrics, m)
+			c.samples = append(c.samples, metrics.Sample{Name: m.sample})
+		}
+	}
+	return c
+}
+
+// Collect snapshots the runtime metrics and expvar variables into a metric log.
+// Cumulative metrics, such as GC cycles and pause times, are reported as the
+// change since the previous collection, or since the process started. Histograms
+// with more than emf.MaxValuesPerMetric values continue in further logs.
+func (c *Collector) Collect() []*emf.MetricLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var logs []*emf.MetricLog
+	logAt := func(part int) *emf.MetricLog {
+		for len(logs) <= part {
+			ml := emf.NewMetricLog(c.config.Namespace)
+			dimensions := make([]string, len(c.config.Dimensions))
+			for i, dim := range c.config.Dimensions {
+				ml.PutDimension(dim.Name, dim.Value)
+				dimensions[i] = dim.Name
+			}
+			ml.WithDimensionSet(dimensions)
+			logs = append(logs, ml)
+		}
+		return logs[part]
+	}
+	ml := logAt(0)
+
+	metrics.Read(c.samples)
+	for i, sample := range c.samples {
+		m := c.metrics[i]
+
+		switch sample.Value.Kind() {
+		case metrics.KindUint64, metrics.KindFloat64:
+			var value float64
+			if sample.Value.Kind() == metrics.KindUint64 {
+				value = float64(sample.Value.Uint64())
+			} else {
+				value = sample.Value.Float64()
+			}
+			if m.cumulative {
+				previous, _ := c.previous[m.sample].(float64)
+				c.previous[m.sample] = value
+				value -= previous
+			}
+			ml.PutMetric(m.name, value, m.unit)
+		case metrics.KindFloat64Histogram:
+			histogram := sample.Value.Float64Histogram()
+			previous, _ := c.previous[m.sample].([]uint64)
+			c.previous[m.sample] = append([]uint64(nil), histogram.Counts...)
+			if value, ok := runtimeHistogramValue(histogram, previous); ok {
+				for part, dist := range value.Split() {
+					logAt(part).PutMetric(m.name, dist, m.unit)
+				}
+			}
+		}
+	}
+
+	for _, name := range c.config.ExpvarNames {
+		putExpvar(ml, name, expvar.Get(name))
+	}
+
+	return logs
+}
+
+// Flush collects the metrics and emits them to the sink.
+func (c *Collector) Flush() error {
+	var errs []error
+	for _, ml := range c.Collect() {
+		if err := c.config.Sink.Emit(ml); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start begins collecting every configured interval in a background goroutine.
+func (c *Collector) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Flush(); err != nil && c.config.OnError != nil {
+					c.config.OnError(err)
+				}
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background collection started by Start.
+func (c *Collector) Stop() {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+}
+
+// runtimeHistogramValue converts the observations of a runtime histogram of seconds
+// since the previous bucket counts to a distribution in milliseconds. Each bucket
+// contributes the midpoint of its bounds; buckets open towards infinity contribute
+// their finite bound.
+func runtimeHistogramValue(histogram *metrics.Float64Histogram, previous []uint64) (emf.Distribution, bool) {
+	var distribution emf.Distribution
+	var stats emf.StatisticSet
+	for i, count := range histogram.Counts {
+		if i < len(previous) && previous[i] <= count {
+			count -= previous[i]
+		}
+		if count == 0 {
+			continue
+		}
+
+		lower, upper := histogram.Buckets[i], histogram.Buckets[i+1]
+		var value float64
+		switch {
+		case math.IsInf(lower, -1):
+			value = upper
+		case math.IsInf(upper, 1):
+			value = lower
+		default:
+			value = (lower + upper) / 2
+		}
+		value *= 1000
+
+		distribution.Values = append(distribution.Values, value)
+		distribution.Counts = append(distribution.Counts, float64(count))
+		stats.ObserveN(value, float64(count))
+	}
+
+	if stats.Count == 0 {
+		return distribution, false
+	}
+	distribution.Max, distribution.Min = stats.Max, stats.Min
+	distribution.Count, distribution.Sum = stats.Count, stats.Sum
+	return distribution, true
+}
+
+// putExpvar adds the numeric values of an expvar variable to the metric log.
+func putExpvar(ml *emf.MetricLog, name string, v expvar.Var) {
+	switch v := v.(type) {
+	case *expvar.Int:
+		ml.PutMetric(name, v.Value(), emf.UnitNone)
+	case *expvar.Float:
+		ml.PutMetric(name, v.Value(), emf.UnitNone)
+	case expvar.Func:
+		if value, ok := number(v.Value()); ok {
+			ml.PutMetric(name, value, emf.UnitNone)
+		}
+	case *expvar.Map:
+		var keys []string
+		v.Do(func(kv expvar.KeyValue) {
+			keys = append(keys, kv.Key)
+		})
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch entry := v.Get(key).(type) {
+			case *expvar.Int, *expvar.Float, expvar.Func:
+				putExpvar(ml, name+"."+key, entry)
+			}
+		}
+	}
+}
+
+// number converts the value of an expvar Func to a float64 if it is a number.
+func number(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,85 @@
+package emfruntime
+
+import (
+	"expvar"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	requests := expvar.NewInt("runtime_test_requests")
+	requests.Add(7)
+	cache := expvar.NewMap("runtime_test_cache")
+	cache.Add("hits", 3)
+	cache.Set("name", &expvar.String{})
+
+	collector := New(Config{
+		Dimensions:  []emf.Dimension{{Name: "ServiceName", Value: "checkout"}},
+		ExpvarNames: []string{"runtime_test_requests", "runtime_test_cache", "missing"},
+	})
+
+	first := collector.Collect()[0]
+	if err := first.Validate(); err != nil {
+		t.Fatalf("Expected a valid metric log, got: %v", err)
+	}
+	if first.Metadata().CloudWatchMetrics[0].Namespace != DefaultNamespace {
+		t.Errorf("Expected namespace %s, got %s", DefaultNamespace, first.Metadata().CloudWatchMetrics[0].Namespace)
+	}
+	if service, _ := first.Value("ServiceName"); service != "checkout" {
+		t.Errorf("Expected the service dimension, got %v", service)
+	}
+
+	units := make(map[string]string)
+	for _, metric := range first.Metadata().CloudWatchMetrics[0].Metrics {
+		units[metric.Name] = *metric.Unit
+	}
+	for name, unit := range map[string]string{
+		"Goroutines":              emf.UnitCount,
+		"HeapInUse":               emf.UnitBytes,
+		"runtime_test_requests":   emf.UnitNone,
+		"runtime_test_cache.hits": emf.UnitNone,
+	} {
+		if units[name] != unit {
+			t.Errorf("Expected %s with unit %q, got %q", name, unit, units[name])
+		}
+	}
+	if _, exists := units["runtime_test_cache.name"]; exists {
+		t.Error("Expected non-numeric expvar entries to be skipped")
+	}
+	if goroutines, _ := first.Value("Goroutines"); goroutines.(float64) < 1 {
+		t.Errorf("Expected at least one goroutine, got %v", goroutines)
+	}
+
+	// Cumulative metrics report the change since the previous collection.
+	runtime.GC()
+	second := collector.Collect()[0]
+	if cycles, _ := second.Value("GCCycles"); cycles.(float64) < 1 {
+		t.Errorf("Expected the forced GC cycle to be counted, got %v", cycles)
+	}
+}
+
+func TestRuntimeHistogramValue(t *testing.T) {
+	histogram := &metrics.Float64Histogram{
+		Counts:  []uint64{1, 3, 2},
+		Buckets: []float64{0, 0.5, 1.5, 2.5},
+	}
+
+	value, ok := runtimeHistogramValue(histogram, []uint64{1, 1, 0})
+	if !ok {
+		t.Fatal("Expected a value")
+	}
+	distribution := value
+	if len(distribution.Values) != 2 || distribution.Values[0] != 1000 || distribution.Values[1] != 2000 {
+		t.Errorf("Expected bucket midpoints in milliseconds, got %v", distribution.Values)
+	}
+	if distribution.Count != 4 || distribution.Counts[0] != 2 || distribution.Counts[1] != 2 {
+		t.Errorf("Expected the observations since the previous counts, got %+v", distribution)
+	}
+
+	if _, ok := runtimeHistogramValue(histogram, histogram.Counts); ok {
+		t.Error("Expected no value without new observations")
+	}
+}
@@ -0,0 +1,124 @@
+package emfstatsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetricType is the type of a StatsD metric.
+type MetricType string
+
+// Metric types
+const (
+	Counter      MetricType = "c"
+	Gauge        MetricType = "g"
+	Timer        MetricType = "ms"
+	Histogram    MetricType = "h"
+	Distribution MetricType = "d"
+	Set          MetricType = "s"
+)
+
+// Tag is a DogStatsD tag. Tags without a value have the value "true".
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single measurement of a StatsD line.
+type Sample struct {
+	Name string
+	Type MetricType
+	// Value is the numeric value of all types but sets.
+	Value float64
+	// SetValue is the member of a set.
+	SetValue string
+	// Relative is set for gauges whose value starts with a sign, which adjust the
+	// current value instead of replacing it.
+	Relative bool
+	// SampleRate is the rate at which the client sampled the measurement, in (0, 1].
+	SampleRate float64
+	Tags       []Tag
+}
+
+// ParseLine parses a StatsD or DogStatsD line of the form
+//
+//	name:value[:value...]|type[|@rate][|#tag:value,tag...]
+//
+// A line with several values yields a sample per value. DogStatsD events and
+// service checks yield no samples.
+func ParseLine(line string) ([]Sample, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return nil, nil
+	}
+
+	fields := strings.Split(line, "|")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid StatsD line %q: missing metric type", line)
+	}
+	name, values, found := strings.Cut(fields[0], ":")
+	if !found || name == "" || values == "" {
+		return nil, fmt.Errorf("invalid StatsD line %q: expected name:value", line)
+	}
+
+	metricType := MetricType(fields[1])
+	switch metricType {
+	case Counter, Gauge, Timer, Histogram, Distribution, Set:
+	default:
+		return nil, fmt.Errorf("invalid StatsD line %q: unknown metric type %q", line, fields[1])
+	}
+
+	sampleRate := 1.0
+	var tags []Tag
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid StatsD line %q: invalid sample rate %q", line, field[1:])
+			}
+			sampleRate = rate
+		case strings.HasPrefix(field, "#"):
+			tags = parseTags(field[1:])
+		}
+		// Other fields, such as DogStatsD container IDs and timestamps, are ignored.
+	}
+
+	if metricType == Set {
+		return []Sample{{Name: name, Type: Set, SetValue: values, SampleRate: sampleRate, Tags: tags}}, nil
+	}
+
+	var samples []Sample
+	for _, raw := range strings.Split(values, ":") {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid StatsD line %q: invalid value %q", line, raw)
+		}
+		samples = append(samples, Sample{
+			Name:       name,
+			Type:       metricType,
+			Value:      value,
+			Relative:   metricType == Gauge && (raw[0] == '+' || raw[0] == '-'),
+			SampleRate: sampleRate,
+			Tags:       tags,
+		})
+	}
+	return samples, nil
+}
+
+// parseTags parses a comma-separated list of DogStatsD tags.
+func parseTags(field string) []Tag {
+	var tags []Tag
+	for _, tag := range strings.Split(field, ",") {
+		if tag == "" {
+			continue
+		}
+		name, value, found := strings.Cut(tag, ":")
+		if !found || value == "" {
+			value = "true"
+		}
+		tags = append(tags, Tag{Name: name, Value: value})
+	}
+	return tags
+}
@@ -0,0 +1,67 @@
+package emfstatsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []Sample
+	}{
+		{
+			line:     "requests:1|c",
+			expected: []Sample{{Name: "requests", Type: Counter, Value: 1, SampleRate: 1}},
+		},
+		{
+			line: "requests:2|c|@0.5|#route:/cart,canary",
+			expected: []Sample{{Name: "requests", Type: Counter, Value: 2, SampleRate: 0.5,
+				Tags: []Tag{{Name: "route", Value: "/cart"}, {Name: "canary", Value: "true"}}}},
+		},
+		{
+			line:     "queue.depth:-3|g",
+			expected: []Sample{{Name: "queue.depth", Type: Gauge, Value: -3, Relative: true, SampleRate: 1}},
+		},
+		{
+			line: "latency:10:20|ms|#env:prod|c:container-1",
+			expected: []Sample{
+				{Name: "latency", Type: Timer, Value: 10, SampleRate: 1, Tags: []Tag{{Name: "env", Value: "prod"}}},
+				{Name: "latency", Type: Timer, Value: 20, SampleRate: 1, Tags: []Tag{{Name: "env", Value: "prod"}}},
+			},
+		},
+		{
+			line:     "users:alice:1|s",
+			expected: []Sample{{Name: "users", Type: Set, SetValue: "alice:1", SampleRate: 1}},
+		},
+		{line: "_e{5,4}:title|text"},
+		{line: "_sc|redis.can_connect|0"},
+		{line: ""},
+	}
+
+	for _, tt := range tests {
+		samples, err := ParseLine(tt.line)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(samples, tt.expected) {
+			t.Errorf("Unexpected samples for %q:\n%+v\nexpected:\n%+v", tt.line, samples, tt.expected)
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for _, line := range []string{
+		"requests",
+		"requests:1",
+		":1|c",
+		"requests:1|x",
+		"requests:abc|c",
+		"requests:1|c|@2",
+	} {
+		if _, err := ParseLine(line); err == nil {
+			t.Errorf("Expected an error for %q", line)
+		}
+	}
+}
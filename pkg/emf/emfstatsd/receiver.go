@@ -0,0 +1,327 @@
+// Package emfstatsd receives StatsD and DogStatsD metrics over UDP and re-emits
+// them as EMF metric logs.
+//
+// Counters, gauges, timers, histograms, distributions and sets are aggregated per
+// flush interval and emitted as one MetricLog per tag set, with the tags as
+// dimensions.
+package emfstatsd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// DefaultAddr is the address StatsD clients send to by default.
+const DefaultAddr = "127.0.0.1:8125"
+
+// DefaultNamespace is the namespace used when Config.Namespace is not set.
+const DefaultNamespace = "StatsD"
+
+// SourceValue is the value of emf.SourceDimension, which is added to metrics
+// without tags since every EMF metric needs a dimension.
+const SourceValue = "statsd"
+
+// Config configures a Receiver.
+type Config struct {
+	// Addr is the UDP address to listen on. Defaults to DefaultAddr.
+	Addr string
+	// Namespace is the CloudWatch namespace of the metrics. Defaults to DefaultNamespace.
+	Namespace string
+	// Sink receives the metric logs. Defaults to a WriterSink on standard output.
+	Sink emf.Sink
+	// Interval is the flush interval. Defaults to emf.DefaultFlushInterval.
+	Interval time.Duration
+	// DefaultDimensions are added to every metric log.
+	DefaultDimensions []emf.Dimension
+	// OnError is called with malformed lines and errors of the background flushes.
+	OnError func(error)
+}
+
+// Receiver aggregates StatsD metrics and emits them as EMF every flush interval.
+type Receiver struct {
+	config Config
+
+	mu     sync.Mutex
+	series map[string]*series
+
+	conn net.PacketConn
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// series aggregates the samples of a metric and tag set within a flush interval.
+type series struct {
+	name       string
+	metricType MetricType
+	tags       []Tag
+
+	// updated is set when the series received samples in the current interval.
+	updated bool
+	count   float64
+	gauge   float64
+	values  map[float64]float64
+	members map[string]struct{}
+}
+
+// New creates a new Receiver with the given configuration. Call Start to begin listening.
+func New(config Config) *Receiver {
+	if config.Addr == "" {
+		config.Addr = DefaultAddr
+	}
+	if config.Namespace == "" {
+		config.Namespace = DefaultNamespace
+	}
+	if config.Sink == nil {
+		config.Sink = emf.NewWriterSink(os.Stdout)
+	}
+	if config.Interval <= 0 {
+		config.Interval = emf.DefaultFlushInterval
+	}
+
+	return &Receiver{
+		config: config,
+		series: make(map[string]*series),
+	}
+}
+
+// Start opens the UDP listener and begins receiving and flushing in the background.
+func (r *Receiver) Start() error {
+	conn, err := net.ListenPacket("udp", r.config.Addr)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	r.stop = make(chan struct{})
+
+	r.wg.Add(2)
+	go r.serve()
+	go r.flushLoop()
+	return nil
+}
+
+// Addr returns the address of the UDP listener, or nil if the receiver is not started.
+func (r *Receiver) Addr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.LocalAddr()
+}
+
+// Close stops the listener and the background flushing and flushes the remaining metrics.
+func (r *Receiver) Close() error {
+	var closeErr error
+	if r.conn != nil {
+		close(r.stop)
+		closeErr = r.conn.Close()
+		r.wg.Wait()
+		r.conn = nil
+	}
+	if errors.Is(closeErr, net.ErrClosed) {
+		closeErr = nil
+	}
+	return errors.Join(closeErr, r.Flush())
+}
+
+// serve handles UDP datagrams until the connection is closed.
+func (r *Receiver) serve() {
+	defer r.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		r.Handle(buf[:n])
+	}
+}
+
+// flushLoop flushes every configured interval until the receiver is closed.
+func (r *Receiver) flushLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil && r.config.OnError != nil {
+				r.config.OnError(err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Handle parses and aggregates a packet of newline-delimited StatsD lines.
+// Malformed lines are reported to the OnError callback and skipped.
+func (r *Receiver) Handle(packet []byte) {
+	for _, line := range bytes.Split(packet, []byte("\n")) {
+		samples, err := ParseLine(string(line))
+		if err != nil {
+			if r.config.OnError != nil {
+				r.config.OnError(err)
+			}
+			continue
+		}
+		for _, sample := range samples {
+			r.Add(sample)
+		}
+	}
+}
+
+// Add aggregates a single sample.
+func (r *Receiver) Add(sample Sample) {
+	tags := append([]Tag(nil), sample.Tags...)
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := sample.Name + "\x00" + string(sample.Type) + "\x00" + tagKey(tags)
+	s, exists := r.series[key]
+	if !exists {
+		s = &series{name: sample.Name, metricType: sample.Type, tags: tags}
+		r.series[key] = s
+	}
+	s.updated = true
+
+	rate := sample.SampleRate
+	if rate <= 0 {
+		rate = 1
+	}
+
+	switch sample.Type {
+	case Counter:
+		s.count += sample.Value / rate
+	case Gauge:
+		if sample.Relative {
+			s.gauge += sample.Value
+		} else {
+			s.gauge = sample.Value
+		}
+	case Timer, Histogram, Distribution:
+		if s.values == nil {
+			s.values = make(map[float64]float64)
+		}
+		s.values[sample.Value] += 1 / rate
+	case Set:
+		if s.members == nil {
+			s.members = make(map[string]struct{})
+		}
+		s.members[sample.SetValue] = struct{}{}
+	}
+}
+
+// Collect returns the metrics aggregated since the previous call as metric logs,
+// one per tag set, and starts a new interval. Gauges keep their value across
+// intervals, so that relative updates apply to the last value, but are only
+// reported for intervals in which they were updated.
+func (r *Receiver) Collect() []*emf.MetricLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.series))
+	for key, s := range r.series {
+		if s.updated {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var logs []*emf.MetricLog
+	byTags := make(map[string]*emf.MetricLog)
+	for _, key := range keys {
+		s := r.series[key]
+
+		// logAt returns the log of the tag set for a part of a split distribution
+		logAt := func(part int) *emf.MetricLog {
+			tags := tagKey(s.tags) + "\x02" + strconv.Itoa(part)
+			ml, exists := byTags[tags]
+			if !exists {
+				ml = r.newMetricLog(s.tags)
+				byTags[tags] = ml
+				logs = append(logs, ml)
+			}
+			return ml
+		}
+
+		switch s.metricType {
+		case Counter:
+			logAt(0).PutMetric(s.name, s.count, emf.UnitCount)
+		case Gauge:
+			logAt(0).PutMetric(s.name, s.gauge, emf.UnitNone)
+		case Timer, Histogram, Distribution:
+			unit := emf.UnitNone
+			if s.metricType == Timer {
+				unit = emf.UnitMilliseconds
+			}
+			for part, dist := range emf.NewDistribution(s.values).Split() {
+				logAt(part).PutMetric(s.name, dist, unit)
+			}
+		case Set:
+			logAt(0).PutMetric(s.name, len(s.members), emf.UnitCount)
+		}
+
+		if s.metricType == Gauge {
+			s.updated = false
+		} else {
+			delete(r.series, key)
+		}
+	}
+	return logs
+}
+
+// Flush emits the metrics aggregated since the previous flush to the sink.
+func (r *Receiver) Flush() error {
+	var errs []error
+	for _, ml := range r.Collect() {
+		errs = append(errs, r.config.Sink.Emit(ml))
+	}
+	return errors.Join(errs...)
+}
+
+// newMetricLog creates a metric log with the tags as dimensions.
+func (r *Receiver) newMetricLog(tags []Tag) *emf.MetricLog {
+	ml := emf.NewMetricLog(r.config.Namespace)
+	for _, dim := range r.config.DefaultDimensions {
+		ml.PutDefaultDimension(dim.Name, dim.Value)
+	}
+
+	var dimensions []string
+	for _, tag := range tags {
+		if _, exists := ml.Value(tag.Name); !exists {
+			dimensions = append(dimensions, tag.Name)
+		}
+		ml.PutDimension(tag.Name, tag.Value)
+	}
+
+	switch {
+	case len(dimensions) > 0:
+		ml.WithDimensionSet(dimensions)
+	case len(r.config.DefaultDimensions) == 0:
+		ml.PutDimension(emf.SourceDimension, SourceValue)
+		ml.WithDimensionSet([]string{emf.SourceDimension})
+	}
+	return ml
+}
+
+// tagKey builds a stable key from sorted tags.
+func tagKey(tags []Tag) string {
+	pairs := make([]string, len(tags))
+	for i, tag := range tags {
+		pairs[i] = tag.Name + "\x01" + tag.Value
+	}
+	return strings.Join(pairs, "\x00")
+}
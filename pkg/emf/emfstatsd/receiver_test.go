@@ -0,0 +1,134 @@
+package emfstatsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+	"github.com/zlatkoc/go-aws-emf/pkg/emf/emftest"
+)
+
+func TestReceiverAggregates(t *testing.T) {
+	rec := emftest.NewRecorder()
+	var errs []error
+	receiver := New(Config{
+		Namespace: "MyApp",
+		Sink:      rec,
+		OnError:   func(err error) { errs = append(errs, err) },
+	})
+
+	receiver.Handle([]byte("requests:1|c|#route:/cart\nrequests:1|c|@0.5|#route:/cart\n" +
+		"latency:10|ms|#route:/cart\nlatency:30:10|ms|#route:/cart\n" +
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n" +
+		"queue:10|g\nqueue:+5|g\n" +
+		"garbage"))
+	if err := receiver.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(errs) != 1 {
+		t.Errorf("Expected the malformed line to be reported, got %v", errs)
+	}
+
+	route := map[string]string{"route": "/cart"}
+	source := map[string]string{emf.SourceDimension: SourceValue}
+	emftest.AssertMetric(t, rec, "MyApp", "requests", route, emftest.All(emftest.Equal(3), emftest.Unit(emf.UnitCount)))
+	emftest.AssertMetric(t, rec, "MyApp", "latency", route, emftest.All(
+		emftest.Equal(emf.Distribution{Values: []float64{10, 30}, Counts: []float64{2, 1}, Max: 30, Min: 10, Count: 3, Sum: 50}),
+		emftest.Unit(emf.UnitMilliseconds)))
+	emftest.AssertMetric(t, rec, "MyApp", "users", source, 2)
+	emftest.AssertMetric(t, rec, "MyApp", "queue", source, 15)
+	emftest.AssertValid(t, rec)
+
+	// Counters start over in every interval, gauges keep their value.
+	rec.Reset()
+	receiver.Handle([]byte("queue:-3|g"))
+	if err := receiver.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	emftest.AssertNoMetric(t, rec, "MyApp", "requests", nil)
+	emftest.AssertMetric(t, rec, "MyApp", "queue", source, 12)
+
+	rec.Reset()
+	if err := receiver.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rec.Logs()) != 0 {
+		t.Errorf("Expected nothing to be emitted without new samples, got %d logs", len(rec.Logs()))
+	}
+}
+
+func TestReceiverSplitsManyValues(t *testing.T) {
+	rec := emftest.NewRecorder()
+	receiver := New(Config{Namespace: "MyApp", Sink: rec})
+
+	var lines []string
+	for i := 0; i <= emf.MaxValuesPerMetric; i++ {
+		lines = append(lines, fmt.Sprintf("latency:%d|ms", i))
+	}
+	receiver.Handle([]byte(strings.Join(lines, "\n")))
+	if err := receiver.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(rec.Logs()) != 2 {
+		t.Fatalf("Expected the timer values to be split across 2 logs, got %d", len(rec.Logs()))
+	}
+	for _, ml := range rec.Logs() {
+		value, _ := ml.Value("latency")
+		if dist, ok := value.(emf.Distribution); !ok || len(dist.Values) > emf.MaxValuesPerMetric {
+			t.Errorf("Expected distributions of at most %d values, got %v", emf.MaxValuesPerMetric, value)
+		}
+	}
+	emftest.AssertValid(t, rec)
+}
+
+func TestReceiverDefaultDimensions(t *testing.T) {
+	rec := emftest.NewRecorder()
+	receiver := New(Config{
+		Sink:              rec,
+		DefaultDimensions: []emf.Dimension{{Name: "Service", Value: "checkout"}},
+	})
+
+	receiver.Handle([]byte("requests:1|c\nerrors:1|c|#code:500"))
+	if err := receiver.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	emftest.AssertMetric(t, rec, DefaultNamespace, "requests", map[string]string{"Service": "checkout"}, 1)
+	emftest.AssertMetric(t, rec, DefaultNamespace, "errors", map[string]string{"Service": "checkout", "code": "500"}, 1)
+}
+
+func TestReceiverUDP(t *testing.T) {
+	rec := emftest.NewRecorder()
+	receiver := New(Config{Addr: "127.0.0.1:0", Sink: rec, Interval: time.Hour})
+	if err := receiver.Start(); err != nil {
+		t.Fatalf("Failed to start receiver: %v", err)
+	}
+
+	conn, err := net.Dial("udp", receiver.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("requests:1|c|#route:/cart"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		receiver.mu.Lock()
+		received := len(receiver.series) > 0
+		receiver.mu.Unlock()
+		if received {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := receiver.Close(); err != nil {
+		t.Fatalf("Failed to close receiver: %v", err)
+	}
+	emftest.AssertMetric(t, rec, DefaultNamespace, "requests", map[string]string{"route": "/cart"}, 1)
+}
@@ -0,0 +1,123 @@
+package emftest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Metric is a single metric of a recorded log, as CloudWatch would see it for one
+// of the log's dimension sets.
+type Metric struct {
+	Namespace         string
+	Name              string
+	Unit              string
+	StorageResolution int
+	Dimensions        map[string]string
+	Value             interface{}
+}
+
+// String returns a readable representation of the metric for failure messages.
+func (m Metric) String() string {
+	return fmt.Sprintf("%s/%s%v = %v %s", m.Namespace, m.Name, m.Dimensions, m.Value, m.Unit)
+}
+
+// FindMetrics returns the recorded metrics with the given namespace and name whose
+// dimensions are exactly dims. A nil dims matches metrics with any dimensions.
+func FindMetrics(rec *Recorder, namespace, name string, dims map[string]string) []Metric {
+	var found []Metric
+	for _, metric := range rec.Metrics() {
+		if metric.Namespace != namespace || metric.Name != name {
+			continue
+		}
+		if dims != nil && !sameDimensions(metric.Dimensions, dims) {
+			continue
+		}
+		found = append(found, metric)
+	}
+	return found
+}
+
+// sameDimensions reports whether both dimension maps hold the same names and values.
+func sameDimensions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, exists := b[key]; !exists || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertMetric fails the test unless a recorded log contains the metric with the given
+// namespace, name and exact dimensions (nil matches any dimensions) whose value matches
+// value. value is either a Matcher or a plain value compared with Equal.
+func AssertMetric(t testing.TB, rec *Recorder, namespace, name string, dims map[string]string, value interface{}) {
+	t.Helper()
+
+	matcher, ok := value.(Matcher)
+	if !ok {
+		matcher = Equal(value)
+	}
+
+	candidates := FindMetrics(rec, namespace, name, dims)
+	for _, metric := range candidates {
+		if matcher.Matches(metric) {
+			return
+		}
+	}
+
+	t.Errorf("expected metric %s/%s%v matching %s, %s", namespace, name, formatDims(dims), matcher, describe(candidates, rec, namespace, name))
+}
+
+// AssertNoMetric fails the test if a recorded log contains the metric with the given
+// namespace, name and exact dimensions (nil matches any dimensions).
+func AssertNoMetric(t testing.TB, rec *Recorder, namespace, name string, dims map[string]string) {
+	t.Helper()
+
+	if found := FindMetrics(rec, namespace, name, dims); len(found) > 0 {
+		t.Errorf("expected no metric %s/%s%v, found:\n%s", namespace, name, formatDims(dims), listMetrics(found))
+	}
+}
+
+// AssertValid fails the test if any recorded log does not pass validation under
+// its validation policy, that is, if it cannot be marshaled.
+func AssertValid(t testing.TB, rec *Recorder) {
+	t.Helper()
+
+	for i, ml := range rec.Logs() {
+		if _, err := ml.MarshalJSON(); err != nil {
+			t.Errorf("recorded log %d is invalid: %v", i, err)
+		}
+	}
+}
+
+// formatDims renders the expected dimensions for failure messages.
+func formatDims(dims map[string]string) string {
+	if dims == nil {
+		return "[any dimensions]"
+	}
+	return fmt.Sprint(dims)
+}
+
+// describe explains what was recorded instead of the expected metric.
+func describe(candidates []Metric, rec *Recorder, namespace, name string) string {
+	if len(candidates) > 0 {
+		return "but the values did not match:\n" + listMetrics(candidates)
+	}
+	if others := FindMetrics(rec, namespace, name, nil); len(others) > 0 {
+		return "but it was recorded with other dimensions:\n" + listMetrics(others)
+	}
+	return fmt.Sprintf("but it was not recorded (%d logs recorded)", len(rec.Logs()))
+}
+
+// listMetrics renders metrics one per line for failure messages.
+func listMetrics(metrics []Metric) string {
+	lines := make([]string, len(metrics))
+	for i, metric := range metrics {
+		lines[i] = "\t" + metric.String()
+	}
+	return strings.Join(lines, "\n")
+}
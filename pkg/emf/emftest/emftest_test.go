@@ -0,0 +1,110 @@
+package emftest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// fakeT records failures instead of failing the enclosing test.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, format)
+}
+
+func record(t *testing.T) *Recorder {
+	t.Helper()
+
+	rec := NewRecorder()
+	logger := emf.NewLogger(emf.LoggerConfig{
+		Namespace:         "TestNamespace",
+		DefaultDimensions: []emf.Dimension{{Name: "Service", Value: "API"}},
+		Sink:              rec,
+	})
+
+	ml := logger.NewMetricLog()
+	ml.PutDimension("Operation", "GetItem")
+	ml.WithDimensionSet([]string{"Operation"})
+	ml.PutMetric("Latency", 42, emf.UnitMilliseconds)
+	ml.PutMetricWithResolution("Requests", []int{1, 1}, emf.UnitCount, emf.StorageResolutionHigh)
+	if err := logger.Emit(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return rec
+}
+
+func TestAssertMetric(t *testing.T) {
+	rec := record(t)
+	dims := map[string]string{"Service": "API", "Operation": "GetItem"}
+
+	AssertMetric(t, rec, "TestNamespace", "Latency", dims, 42.0)
+	AssertMetric(t, rec, "TestNamespace", "Latency", dims, All(InDelta(40, 5), Unit(emf.UnitMilliseconds)))
+	AssertMetric(t, rec, "TestNamespace", "Latency", nil, Between(0, 100))
+	AssertMetric(t, rec, "TestNamespace", "Requests", dims, All(Equal([]float64{1, 1}), Resolution(emf.StorageResolutionHigh)))
+	AssertNoMetric(t, rec, "TestNamespace", "Latency", map[string]string{"Service": "API"})
+	AssertNoMetric(t, rec, "OtherNamespace", "Latency", nil)
+	AssertValid(t, rec)
+}
+
+func TestAssertMetricFailures(t *testing.T) {
+	rec := record(t)
+	dims := map[string]string{"Service": "API", "Operation": "GetItem"}
+
+	cases := []func(tb testing.TB){
+		func(tb testing.TB) { AssertMetric(tb, rec, "TestNamespace", "Latency", dims, 41) },
+		func(tb testing.TB) { AssertMetric(tb, rec, "TestNamespace", "Latency", dims, Unit(emf.UnitSeconds)) },
+		func(tb testing.TB) {
+			AssertMetric(tb, rec, "TestNamespace", "Latency", map[string]string{"Service": "API"}, Any())
+		},
+		func(tb testing.TB) { AssertMetric(tb, rec, "TestNamespace", "Missing", nil, Any()) },
+		func(tb testing.TB) { AssertNoMetric(tb, rec, "TestNamespace", "Latency", nil) },
+	}
+	for i, assert := range cases {
+		ft := &fakeT{}
+		assert(ft)
+		if len(ft.errors) != 1 {
+			t.Errorf("Case %d: expected 1 failure, got %d", i, len(ft.errors))
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder()
+
+	invalid := emf.NewMetricLog("TestNamespace")
+	invalid.WithDimensionSet([]string{"Missing"})
+	invalid.PutMetric("Latency", 1, emf.UnitMilliseconds)
+	if err := rec.Emit(invalid); err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+	if len(rec.Logs()) != 1 {
+		t.Fatalf("Expected the invalid log to be recorded, got %d logs", len(rec.Logs()))
+	}
+
+	// The log's validation policy applies, as in a real sink
+	lenient := emf.NewMetricLog("TestNamespace").SetValidationPolicy(emf.ValidationLenient, nil)
+	lenient.PutDimension("Service", "API")
+	lenient.WithDimensionSet([]string{"Service", "Missing"})
+	lenient.PutMetric("Latency", 1, emf.UnitMilliseconds)
+	if err := rec.Emit(lenient); err != nil {
+		t.Errorf("Expected the lenient log to be accepted, got %v", err)
+	}
+
+	ft := &fakeT{}
+	AssertValid(ft, rec)
+	if len(ft.errors) != 1 {
+		t.Errorf("Expected AssertValid to fail, got %d failures", len(ft.errors))
+	}
+
+	rec.Reset()
+	if len(rec.Logs()) != 0 || len(rec.Metrics()) != 0 {
+		t.Error("Expected an empty recorder after a reset")
+	}
+}
@@ -0,0 +1,147 @@
+package emftest
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Matcher decides whether a recorded metric is the one a test expects.
+type Matcher interface {
+	Matches(m Metric) bool
+	String() string
+}
+
+type matcherFunc struct {
+	match       func(m Metric) bool
+	description string
+}
+
+func (f matcherFunc) Matches(m Metric) bool { return f.match(m) }
+func (f matcherFunc) String() string        { return f.description }
+
+// Any matches every metric, whatever its value.
+func Any() Matcher {
+	return matcherFunc{func(Metric) bool { return true }, "any value"}
+}
+
+// Equal matches metrics whose value equals expected. Numbers are compared by
+// value regardless of their Go type, and slices element by element.
+func Equal(expected interface{}) Matcher {
+	return matcherFunc{
+		func(m Metric) bool { return valuesEqual(m.Value, expected) },
+		fmt.Sprintf("value %v", expected),
+	}
+}
+
+// InDelta matches numeric metrics whose value is within delta of expected.
+func InDelta(expected, delta float64) Matcher {
+	return numberMatcher(func(v float64) bool { return math.Abs(v-expected) <= delta },
+		fmt.Sprintf("value %v ± %v", expected, delta))
+}
+
+// GreaterThan matches numeric metrics whose value is greater than bound.
+func GreaterThan(bound float64) Matcher {
+	return numberMatcher(func(v float64) bool { return v > bound }, fmt.Sprintf("value > %v", bound))
+}
+
+// LessThan matches numeric metrics whose value is less than bound.
+func LessThan(bound float64) Matcher {
+	return numberMatcher(func(v float64) bool { return v < bound }, fmt.Sprintf("value < %v", bound))
+}
+
+// Between matches numeric metrics whose value lies within [lo, hi].
+func Between(lo, hi float64) Matcher {
+	return numberMatcher(func(v float64) bool { return v >= lo && v <= hi },
+		fmt.Sprintf("value in [%v, %v]", lo, hi))
+}
+
+// Unit matches metrics declared with the given unit.
+func Unit(unit string) Matcher {
+	return matcherFunc{
+		func(m Metric) bool { return m.Unit == unit },
+		fmt.Sprintf("unit %s", unit),
+	}
+}
+
+// Resolution matches metrics declared with the given storage resolution.
+func Resolution(resolution int) Matcher {
+	return matcherFunc{
+		func(m Metric) bool { return m.StorageResolution == resolution },
+		fmt.Sprintf("storage resolution %d", resolution),
+	}
+}
+
+// All matches metrics that satisfy every given matcher.
+func All(matchers ...Matcher) Matcher {
+	descriptions := make([]string, len(matchers))
+	for i, matcher := range matchers {
+		descriptions[i] = matcher.String()
+	}
+	return matcherFunc{
+		func(m Metric) bool {
+			for _, matcher := range matchers {
+				if !matcher.Matches(m) {
+					return false
+				}
+			}
+			return true
+		},
+		strings.Join(descriptions, " and "),
+	}
+}
+
+// numberMatcher matches metrics with a single numeric value satisfying match.
+func numberMatcher(match func(float64) bool, description string) Matcher {
+	return matcherFunc{
+		func(m Metric) bool {
+			v, ok := toFloat64(m.Value)
+			return ok && match(v)
+		},
+		description,
+	}
+}
+
+// valuesEqual compares a recorded value with an expected one, treating all
+// numeric types alike.
+func valuesEqual(actual, expected interface{}) bool {
+	if a, ok := toFloat64(actual); ok {
+		e, ok := toFloat64(expected)
+		return ok && a == e
+	}
+
+	actualValue, expectedValue := reflect.ValueOf(actual), reflect.ValueOf(expected)
+	if isSlice(actualValue) && isSlice(expectedValue) {
+		if actualValue.Len() != expectedValue.Len() {
+			return false
+		}
+		for i := 0; i < actualValue.Len(); i++ {
+			if !valuesEqual(actualValue.Index(i).Interface(), expectedValue.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(actual, expected)
+}
+
+func isSlice(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice || v.Kind() == reflect.Array
+}
+
+// toFloat64 converts any numeric value to float64.
+func toFloat64(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
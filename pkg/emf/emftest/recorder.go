@@ -0,0 +1,93 @@
+// Package emftest provides a recording sink and assertion helpers for testing
+// code that emits EMF metric logs.
+package emftest
+
+import (
+	"sync"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// Recorder is an emf.Sink that keeps every emitted metric log in memory.
+type Recorder struct {
+	mu   sync.Mutex
+	logs []*emf.MetricLog
+}
+
+// NewRecorder creates a new, empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Emit records the metric log. Like a real sink it marshals the log, applying
+// its validation policy; logs that fail are recorded as well, but the error is
+// returned.
+func (r *Recorder) Emit(ml *emf.MetricLog) error {
+	r.mu.Lock()
+	r.logs = append(r.logs, ml)
+	r.mu.Unlock()
+
+	_, err := ml.MarshalJSON()
+	return err
+}
+
+// Logs returns the recorded metric logs in the order they were emitted.
+func (r *Recorder) Logs() []*emf.MetricLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*emf.MetricLog(nil), r.logs...)
+}
+
+// Metrics returns every metric of the recorded logs, once per dimension set,
+// in the order they were emitted.
+func (r *Recorder) Metrics() []Metric {
+	var metrics []Metric
+	for _, ml := range r.Logs() {
+		metrics = append(metrics, metricsOf(ml)...)
+	}
+	return metrics
+}
+
+// Reset discards all recorded metric logs.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = nil
+}
+
+// metricsOf returns the metrics of a single log, once per dimension set.
+func metricsOf(ml *emf.MetricLog) []Metric {
+	var metrics []Metric
+	for _, directive := range ml.Metadata().CloudWatchMetrics {
+		for _, def := range directive.Metrics {
+			unit := ""
+			if def.Unit != nil {
+				unit = *def.Unit
+			}
+			resolution := emf.StorageResolutionStandard
+			if def.StorageResolution != nil {
+				resolution = *def.StorageResolution
+			}
+			value, _ := ml.Value(def.Name)
+
+			for _, dimSet := range directive.Dimensions {
+				dimensions := make(map[string]string, len(dimSet))
+				for _, dim := range dimSet {
+					if dimValue, exists := ml.Value(dim); exists {
+						dimensions[dim], _ = dimValue.(string)
+					}
+				}
+
+				metrics = append(metrics, Metric{
+					Namespace:         directive.Namespace,
+					Name:              def.Name,
+					Unit:              unit,
+					StorageResolution: resolution,
+					Dimensions:        dimensions,
+					Value:             value,
+				})
+			}
+		}
+	}
+	return metrics
+}
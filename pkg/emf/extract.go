@@ -0,0 +1,219 @@
+package emf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricDatum is a single CloudWatch metric datum that an EMF event produces,
+// in the shape of a PutMetricData datum.
+type MetricDatum struct {
+	Namespace         string
+	MetricName        string
+	Dimensions        map[string]string
+	Unit              string
+	StorageResolution int
+	Timestamp         time.Time
+	// Values and Counts hold the individual samples of the datum, each value
+	// observed Counts[i] times. They are empty for statistic sets.
+	Values []float64
+	Counts []float64
+	// StatisticValues summarizes the samples. It is set for statistic sets and
+	// distributions, which carry their own statistics.
+	StatisticValues *StatisticSet
+}
+
+// SeriesKey identifies the CloudWatch series of the datum by its namespace, metric
+// name and dimensions. Datums of the same series have the same key.
+func (d MetricDatum) SeriesKey() string {
+	keys := make([]string, 0, len(d.Dimensions))
+	for key := range d.Dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(d.Namespace)
+	sb.WriteByte(0)
+	sb.WriteString(d.MetricName)
+	for _, key := range keys {
+		sb.WriteByte(0)
+		sb.WriteString(key)
+		sb.WriteByte(1)
+		sb.WriteString(d.Dimensions[key])
+	}
+	return sb.String()
+}
+
+// Statistics returns the summary statistics of the datum.
+func (d MetricDatum) Statistics() StatisticSet {
+	if d.StatisticValues != nil {
+		return *d.StatisticValues
+	}
+	var stats StatisticSet
+	for i, value := range d.Values {
+		stats.ObserveN(value, d.Counts[i])
+	}
+	return stats
+}
+
+// ExtractMetrics returns the metric datums CloudWatch extracts from the metric log:
+// one per metric and dimension set of every metric directive, in the directive's
+// namespace. Default dimensions are applied as they are when marshaling.
+//
+// Like CloudWatch, it skips metrics whose value is missing or not numeric and
+// dimension sets that reference a missing dimension value. Use Validate to find out
+// why a metric log yields fewer datums than expected.
+func ExtractMetrics(ml *MetricLog) []MetricDatum {
+	ml = ml.resolved()
+
+	var datums []MetricDatum
+	for _, directive := range ml.emf.Aws.CloudWatchMetrics {
+		for _, metric := range directive.Metrics {
+			unit := UnitNone
+			if metric.Unit != nil {
+				unit = *metric.Unit
+			}
+			resolution := StorageResolutionStandard
+			if metric.StorageResolution != nil {
+				resolution = *metric.StorageResolution
+			}
+
+			values, counts, statistics, ok := decodeMetricValue(ml.metrics[metric.Name])
+			if !ok {
+				continue
+			}
+
+			for _, dimSet := range directive.Dimensions {
+				dimensions, ok := dimensionValues(ml, dimSet)
+				if !ok {
+					continue
+				}
+
+				datums = append(datums, MetricDatum{
+					Namespace:         directive.Namespace,
+					MetricName:        metric.Name,
+					Dimensions:        dimensions,
+					Unit:              unit,
+					StorageResolution: resolution,
+					Timestamp:         ml.Timestamp(),
+					Values:            values,
+					Counts:            counts,
+					StatisticValues:   statistics,
+				})
+			}
+		}
+	}
+	return datums
+}
+
+// ExtractMetricsJSON parses and validates a serialized EMF event and returns the
+// metric datums CloudWatch extracts from it.
+func ExtractMetricsJSON(data []byte) ([]MetricDatum, error) {
+	ml, err := ParseMetricLog(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := ml.Validate(); err != nil {
+		return nil, err
+	}
+	return ExtractMetrics(ml), nil
+}
+
+// dimensionValues resolves the values of a dimension set.
+func dimensionValues(ml *MetricLog, dimSet []string) (map[string]string, bool) {
+	dimensions := make(map[string]string, len(dimSet))
+	for _, dim := range dimSet {
+		value, exists := ml.metrics[dim]
+		if !exists {
+			return nil, false
+		}
+		dimensions[dim] = fmt.Sprint(value)
+	}
+	return dimensions, true
+}
+
+// decodeMetricValue decodes a metric value, which may be a number, a slice of
+// numbers, a StatisticSet or Distribution, or their parsed JSON object form.
+func decodeMetricValue(raw interface{}) (values, counts []float64, statistics *StatisticSet, ok bool) {
+	switch v := raw.(type) {
+	case StatisticSet:
+		return nil, nil, &v, v.Count > 0
+	case *StatisticSet:
+		if v == nil {
+			return nil, nil, nil, false
+		}
+		return decodeMetricValue(*v)
+	case Distribution:
+		if len(v.Values) == 0 {
+			// Distributions without values, as the Aggregator emits for statistic sets
+			return decodeMetricValue(v.Statistics())
+		}
+		// Missing counts default to 1, as in the parsed JSON form
+		counts = make([]float64, len(v.Values))
+		for i := range counts {
+			counts[i] = 1
+			if i < len(v.Counts) {
+				counts[i] = v.Counts[i]
+			}
+		}
+		stats := v.Statistics()
+		return v.Values, counts, &stats, true
+	case *Distribution:
+		if v == nil {
+			return nil, nil, nil, false
+		}
+		return decodeMetricValue(*v)
+	case map[string]interface{}:
+		return decodeAggregatedValue(v)
+	}
+
+	values, ok = toFloat64Slice(raw)
+	if !ok || len(values) == 0 {
+		return nil, nil, nil, false
+	}
+	counts = make([]float64, len(values))
+	for i := range counts {
+		counts[i] = 1
+	}
+	return values, counts, nil, true
+}
+
+// decodeAggregatedValue decodes the parsed JSON form of a statistic set or distribution.
+func decodeAggregatedValue(v map[string]interface{}) (values, counts []float64, statistics *StatisticSet, ok bool) {
+	maximum, hasMax := toFloat64(v["Max"])
+	minimum, hasMin := toFloat64(v["Min"])
+	sum, hasSum := toFloat64(v["Sum"])
+	count, hasCount := toFloat64(v["Count"])
+	if !hasCount {
+		count, hasCount = toFloat64(v["SampleCount"])
+	}
+	if hasMax && hasMin && hasSum && hasCount {
+		statistics = &StatisticSet{Max: maximum, Min: minimum, Count: count, Sum: sum}
+	}
+
+	rawValues, _ := v["Values"].([]interface{})
+	rawCounts, _ := v["Counts"].([]interface{})
+	for i, item := range rawValues {
+		value, isNumber := toFloat64(item)
+		if !isNumber {
+			continue
+		}
+		count := 1.0
+		if i < len(rawCounts) {
+			if c, isNumber := toFloat64(rawCounts[i]); isNumber {
+				count = c
+			}
+		}
+		values = append(values, value)
+		counts = append(counts, count)
+	}
+
+	if len(values) == 0 {
+		// Distributions without values are statistic sets
+		return nil, nil, statistics, statistics != nil && statistics.Count > 0
+	}
+	return values, counts, statistics, true
+}
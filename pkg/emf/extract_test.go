@@ -0,0 +1,163 @@
+package emf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractMetricsPerDimensionSet(t *testing.T) {
+	ml := newRequestLog("API", 42)
+	ml.PutDefaultDimension("Environment", "prod")
+	ml.PutDimension("Region", "us-west-2")
+	ml.WithDimensionSet([]string{"Service", "Region"})
+
+	datums := ExtractMetrics(ml)
+	if len(datums) != 4 {
+		t.Fatalf("Expected 2 metrics x 2 dimension sets, got %d datums", len(datums))
+	}
+
+	first := datums[0]
+	if first.Namespace != "TestNamespace" || first.MetricName != "Latency" || first.Unit != UnitMilliseconds ||
+		first.StorageResolution != StorageResolutionStandard || !first.Timestamp.Equal(ml.Timestamp()) {
+		t.Errorf("Unexpected datum: %+v", first)
+	}
+	if want := map[string]string{"Environment": "prod", "Service": "API"}; !reflect.DeepEqual(first.Dimensions, want) {
+		t.Errorf("Expected dimensions %v, got %v", want, first.Dimensions)
+	}
+	if !reflect.DeepEqual(first.Values, []float64{42}) || !reflect.DeepEqual(first.Counts, []float64{1}) {
+		t.Errorf("Unexpected values %v and counts %v", first.Values, first.Counts)
+	}
+	if want := map[string]string{"Environment": "prod", "Service": "API", "Region": "us-west-2"}; !reflect.DeepEqual(datums[1].Dimensions, want) {
+		t.Errorf("Expected dimensions %v, got %v", want, datums[1].Dimensions)
+	}
+}
+
+func TestExtractMetricsAggregatedValues(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Size", StatisticSet{Max: 10, Min: 1, Count: 4, Sum: 20}, UnitBytes)
+	ml.PutMetric("Latency", Distribution{Values: []float64{5, 15}, Counts: []float64{3, 1}, Max: 15, Min: 5, Count: 4, Sum: 30}, UnitMilliseconds)
+	ml.PutMetricWithResolution("Requests", []int{1, 2, 3}, UnitCount, StorageResolutionHigh)
+	ml.PutMetric("Broken", "not a number", UnitCount)
+
+	datums := ExtractMetrics(ml)
+	if len(datums) != 3 {
+		t.Fatalf("Expected the non-numeric metric to be skipped, got %d datums", len(datums))
+	}
+
+	expected := map[string]StatisticSet{
+		"Size":     {Max: 10, Min: 1, Count: 4, Sum: 20},
+		"Latency":  {Max: 15, Min: 5, Count: 4, Sum: 30},
+		"Requests": {Max: 3, Min: 1, Count: 3, Sum: 6},
+	}
+	byName := make(map[string]MetricDatum)
+	for _, datum := range datums {
+		if got := datum.Statistics(); got != expected[datum.MetricName] {
+			t.Errorf("Expected statistics %+v for %s, got %+v", expected[datum.MetricName], datum.MetricName, got)
+		}
+		byName[datum.MetricName] = datum
+	}
+	if byName["Size"].Values != nil || byName["Size"].StatisticValues == nil {
+		t.Errorf("Expected a statistic set datum without values, got %+v", byName["Size"])
+	}
+	if byName["Requests"].StorageResolution != StorageResolutionHigh {
+		t.Errorf("Expected high resolution, got %d", byName["Requests"].StorageResolution)
+	}
+}
+
+func TestExtractMetricsEmptyDistribution(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Size", Distribution{Values: []float64{}, Counts: []float64{}, Max: 10, Min: 1, Count: 4, Sum: 20}, UnitBytes)
+	ml.PutMetric("Empty", Distribution{Values: []float64{}, Counts: []float64{}}, UnitBytes)
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	parsed, err := ExtractMetricsJSON(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	live := ExtractMetrics(ml)
+	if !reflect.DeepEqual(live, parsed) {
+		t.Errorf("Expected the same datums before and after a JSON round trip, got %+v and %+v", live, parsed)
+	}
+	if len(live) != 1 || live[0].StatisticValues == nil || live[0].Statistics() != (StatisticSet{Max: 10, Min: 1, Count: 4, Sum: 20}) {
+		t.Errorf("Expected a statistic set datum for Size only, got %+v", live)
+	}
+}
+
+func TestExtractMetricsDistributionWithoutCounts(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Latency", Distribution{Values: []float64{5, 15}, Max: 15, Min: 5, Count: 2, Sum: 20}, UnitMilliseconds)
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	parsed, err := ExtractMetricsJSON(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	live := ExtractMetrics(ml)
+	if len(live) != 1 || !reflect.DeepEqual(live[0].Counts, []float64{1, 1}) {
+		t.Fatalf("Expected the counts to default to 1, got %+v", live)
+	}
+	if !reflect.DeepEqual(live, parsed) {
+		t.Errorf("Expected the same datums before and after a JSON round trip, got %+v and %+v", live, parsed)
+	}
+}
+
+func TestMetricDatumSeriesKey(t *testing.T) {
+	base := MetricDatum{Namespace: "App", MetricName: "Latency", Dimensions: map[string]string{"Service": "API", "Region": "eu-west-1"}}
+	same := MetricDatum{Namespace: "App", MetricName: "Latency", Dimensions: map[string]string{"Region": "eu-west-1", "Service": "API"}, Values: []float64{1}}
+	if base.SeriesKey() != same.SeriesKey() {
+		t.Error("Expected datums of the same series to have the same key")
+	}
+
+	for _, other := range []MetricDatum{
+		{Namespace: "Other", MetricName: "Latency", Dimensions: base.Dimensions},
+		{Namespace: "App", MetricName: "Errors", Dimensions: base.Dimensions},
+		{Namespace: "App", MetricName: "Latency", Dimensions: map[string]string{"Service": "API"}},
+		{Namespace: "App", MetricName: "Latency", Dimensions: map[string]string{"Service": "API", "Region": "us-east-1"}},
+	} {
+		if other.SeriesKey() == base.SeriesKey() {
+			t.Errorf("Expected a different key for %+v", other)
+		}
+	}
+}
+
+func TestExtractMetricsJSON(t *testing.T) {
+	input := `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[` +
+		`{"Namespace":"A","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]},` +
+		`{"Namespace":"B","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency"}]}]},` +
+		`"Service":"API","Latency":{"Values":[5,15],"Counts":[3,1],"Max":15,"Min":5,"Count":4,"Sum":30}}`
+
+	datums, err := ExtractMetricsJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(datums) != 2 || datums[0].Namespace != "A" || datums[1].Namespace != "B" {
+		t.Fatalf("Expected a datum per namespace, got %+v", datums)
+	}
+	if datums[1].Unit != UnitNone {
+		t.Errorf("Expected a datum without unit, got %+v", datums[1])
+	}
+	if !reflect.DeepEqual(datums[0].Values, []float64{5, 15}) || !reflect.DeepEqual(datums[0].Counts, []float64{3, 1}) {
+		t.Errorf("Unexpected values %v and counts %v", datums[0].Values, datums[0].Counts)
+	}
+	if datums[0].Timestamp.UnixMilli() != 1600000000000 {
+		t.Errorf("Unexpected timestamp %v", datums[0].Timestamp)
+	}
+
+	if _, err := ExtractMetricsJSON([]byte(`{"not": "emf"}`)); err == nil {
+		t.Error("Expected an error for a non-EMF event")
+	}
+}
@@ -0,0 +1,315 @@
+package emf
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// instrumentKind identifies the type of a registered instrument.
+type instrumentKind int
+
+const (
+	kindCounter instrumentKind = iota
+	kindGauge
+	kindHistogram
+)
+
+// String returns the name of the instrument kind.
+func (k instrumentKind) String() string {
+	switch k {
+	case kindCounter:
+		return "counter"
+	case kindGauge:
+		return "gauge"
+	default:
+		return "histogram"
+	}
+}
+
+// Registry holds named instruments for a single namespace and renders
+// their current state as metric logs on every flush.
+type Registry struct {
+	namespace string
+
+	mu          sync.Mutex
+	instruments []*instrument
+	byName      map[string]*instrument
+}
+
+// instrument is the shared state behind Counter, Gauge and Histogram.
+type instrument struct {
+	kind          instrumentKind
+	name          string
+	unit          string
+	dimensionKeys []string
+
+	mu     sync.Mutex
+	series map[string]*series
+	order  []string
+}
+
+// series holds the state of an instrument for one combination of dimension values.
+type series struct {
+	dimensionValues []string
+	total           float64
+	reported        float64
+	value           float64
+	set             bool
+	counts          map[float64]float64
+	// updated reports whether the series changed since the previous flush.
+	updated bool
+}
+
+// Counter is a monotonically increasing value. Each flush emits the increase
+// since the previous flush, for the series updated since then.
+type Counter struct {
+	instrument *instrument
+}
+
+// Gauge is a value that can go up and down. Each flush emits the last value set.
+type Gauge struct {
+	instrument *instrument
+}
+
+// Histogram records observations. Each flush emits the observations made since
+// the previous flush as a Distribution of values and counts, split across several
+// metric logs if there are more than MaxValuesPerMetric distinct values.
+type Histogram struct {
+	instrument *instrument
+}
+
+// NewRegistry creates a new, empty Registry for the given namespace.
+func NewRegistry(namespace string) *Registry {
+	return &Registry{
+		namespace: namespace,
+		byName:    make(map[string]*instrument),
+	}
+}
+
+// Counter registers a counter with the given name, unit and dimension keys, or returns
+// the existing counter of that name. It panics if the name is already used by a
+// different kind of instrument or with different dimension keys.
+func (r *Registry) Counter(name, unit string, dimensionKeys ...string) *Counter {
+	return &Counter{instrument: r.register(kindCounter, name, unit, dimensionKeys)}
+}
+
+// Gauge registers a gauge with the given name, unit and dimension keys, or returns
+// the existing gauge of that name. It panics under the same conditions as Counter.
+func (r *Registry) Gauge(name, unit string, dimensionKeys ...string) *Gauge {
+	return &Gauge{instrument: r.register(kindGauge, name, unit, dimensionKeys)}
+}
+
+// Histogram registers a histogram with the given name, unit and dimension keys, or returns
+// the existing histogram of that name. It panics under the same conditions as Counter.
+func (r *Registry) Histogram(name, unit string, dimensionKeys ...string) *Histogram {
+	return &Histogram{instrument: r.register(kindHistogram, name, unit, dimensionKeys)}
+}
+
+// register returns the instrument with the given name, creating it if needed.
+func (r *Registry) register(kind instrumentKind, name, unit string, dimensionKeys []string) *instrument {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, exists := r.byName[name]; exists {
+		if existing.kind != kind || strings.Join(existing.dimensionKeys, "\x00") != strings.Join(dimensionKeys, "\x00") {
+			panic(fmt.Sprintf("emf: instrument '%s' is already registered as a %s with dimensions %v",
+				name, existing.kind, existing.dimensionKeys))
+		}
+		return existing
+	}
+
+	inst := &instrument{
+		kind:          kind,
+		name:          name,
+		unit:          unit,
+		dimensionKeys: append([]string(nil), dimensionKeys...),
+		series:        make(map[string]*series),
+	}
+	r.instruments = append(r.instruments, inst)
+	r.byName[name] = inst
+	return inst
+}
+
+// Add increases the counter by delta for the given dimension values.
+// Negative deltas are ignored because counters are monotonic, and NaN and
+// infinite deltas because CloudWatch rejects them.
+func (c *Counter) Add(delta float64, dimensionValues ...string) {
+	if delta < 0 || !isFinite(delta) {
+		return
+	}
+	c.instrument.update(dimensionValues, func(s *series) {
+		s.total += delta
+	})
+}
+
+// Inc increases the counter by one for the given dimension values.
+func (c *Counter) Inc(dimensionValues ...string) {
+	c.Add(1, dimensionValues...)
+}
+
+// Set sets the gauge to value for the given dimension values. NaN and infinite
+// values are ignored.
+func (g *Gauge) Set(value float64, dimensionValues ...string) {
+	if !isFinite(value) {
+		return
+	}
+	g.instrument.update(dimensionValues, func(s *series) {
+		s.value = value
+		s.set = true
+	})
+}
+
+// Add adds delta (which may be negative) to the gauge for the given dimension values.
+// NaN and infinite deltas are ignored.
+func (g *Gauge) Add(delta float64, dimensionValues ...string) {
+	if !isFinite(delta) {
+		return
+	}
+	g.instrument.update(dimensionValues, func(s *series) {
+		s.value += delta
+		s.set = true
+	})
+}
+
+// Observe records a single observation for the given dimension values. NaN and
+// infinite values are ignored.
+func (h *Histogram) Observe(value float64, dimensionValues ...string) {
+	if !isFinite(value) {
+		return
+	}
+	h.instrument.update(dimensionValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make(map[float64]float64)
+		}
+		s.counts[value]++
+	})
+}
+
+// update applies fn to the series for the given dimension values.
+// It panics if the number of values does not match the instrument's dimension keys.
+func (i *instrument) update(dimensionValues []string, fn func(s *series)) {
+	if len(dimensionValues) != len(i.dimensionKeys) {
+		panic(fmt.Sprintf("emf: instrument '%s' expects %d dimension values, got %d",
+			i.name, len(i.dimensionKeys), len(dimensionValues)))
+	}
+
+	key := strings.Join(dimensionValues, "\x00")
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	s, exists := i.series[key]
+	if !exists {
+		s = &series{dimensionValues: append([]string(nil), dimensionValues...)}
+		i.series[key] = s
+		i.order = append(i.order, key)
+	}
+	fn(s)
+	s.updated = true
+}
+
+// sample is the value reported for one series of an instrument. Histograms with
+// more than MaxValuesPerMetric distinct values have several values, which are
+// reported in separate metric logs.
+type sample struct {
+	dimensionValues []string
+	values          []interface{}
+}
+
+// collect returns the values to report for every series and advances the
+// instrument's state to the next flush interval.
+func (i *instrument) collect() []sample {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var samples []sample
+	for _, key := range i.order {
+		s := i.series[key]
+		switch i.kind {
+		case kindCounter:
+			if !s.updated {
+				continue
+			}
+			samples = append(samples, sample{s.dimensionValues, []interface{}{s.total - s.reported}})
+			s.reported = s.total
+		case kindGauge:
+			if s.set {
+				samples = append(samples, sample{s.dimensionValues, []interface{}{s.value}})
+			}
+		case kindHistogram:
+			if len(s.counts) == 0 {
+				continue
+			}
+			var values []interface{}
+			for _, dist := range NewDistribution(s.counts).Split() {
+				values = append(values, dist)
+			}
+			samples = append(samples, sample{s.dimensionValues, values})
+			s.counts = nil
+		}
+		s.updated = false
+	}
+	return samples
+}
+
+// Collect renders the current state of all instruments as metric logs, one per
+// combination of dimension keys and values, and starts a new flush interval.
+// Instruments registered without dimension keys are emitted with the SourceDimension.
+func (r *Registry) Collect() []*MetricLog {
+	r.mu.Lock()
+	instruments := append([]*instrument(nil), r.instruments...)
+	r.mu.Unlock()
+
+	var logs []*MetricLog
+	byKey := make(map[string]*MetricLog)
+
+	for _, inst := range instruments {
+		// Instruments without dimensions get the SourceDimension
+		dimensionKeys := inst.dimensionKeys
+		if len(dimensionKeys) == 0 {
+			dimensionKeys = []string{SourceDimension}
+		}
+
+		for _, sample := range inst.collect() {
+			dimensions := make(map[string]string, len(dimensionKeys))
+			for j, dimKey := range inst.dimensionKeys {
+				dimensions[dimKey] = sample.dimensionValues[j]
+			}
+			if len(inst.dimensionKeys) == 0 {
+				dimensions[SourceDimension] = SourceValue
+			}
+
+			// The parts of a split histogram go to separate logs
+			for part, value := range sample.values {
+				logKey := aggregationKey(r.namespace, [][]string{dimensionKeys}, dimensions) + "\x00" + strconv.Itoa(part)
+				ml, exists := byKey[logKey]
+				if !exists {
+					ml = NewMetricLog(r.namespace)
+					for _, dimKey := range dimensionKeys {
+						ml.PutDimension(dimKey, dimensions[dimKey])
+					}
+					ml.WithDimensionSet(append([]string(nil), dimensionKeys...))
+					byKey[logKey] = ml
+					logs = append(logs, ml)
+				}
+				ml.PutMetric(inst.name, value, inst.unit)
+			}
+		}
+	}
+
+	return logs
+}
+
+// Flush collects all instruments and emits the resulting metric logs to the sink.
+func (r *Registry) Flush(sink Sink) error {
+	var errs []error
+	for _, ml := range r.Collect() {
+		if err := sink.Emit(ml); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,224 @@
+package emf
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCounterEmitsDeltas(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	requests := registry.Counter("Requests", UnitCount, "Service")
+
+	requests.Inc("API")
+	requests.Add(2, "API")
+	requests.Add(-5, "API") // ignored, counters are monotonic
+
+	logs := registry.Collect()
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log, got %d", len(logs))
+	}
+	if logs[0].metrics["Requests"] != 3.0 {
+		t.Errorf("Expected a delta of 3, got %v", logs[0].metrics["Requests"])
+	}
+
+	requests.Inc("API")
+	logs = registry.Collect()
+	if logs[0].metrics["Requests"] != 1.0 {
+		t.Errorf("Expected a delta of 1 after the second flush, got %v", logs[0].metrics["Requests"])
+	}
+
+	if logs := registry.Collect(); len(logs) != 0 {
+		t.Errorf("Expected no logs without new increments, got %d", len(logs))
+	}
+
+	requests.Add(math.NaN(), "API")
+	requests.Add(math.Inf(1), "API")
+	if logs := registry.Collect(); len(logs) != 0 {
+		t.Errorf("Expected non-finite deltas to be ignored, got %d logs", len(logs))
+	}
+}
+
+func TestGaugeEmitsLastValue(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	queueDepth := registry.Gauge("QueueDepth", UnitCount, "Queue")
+
+	queueDepth.Set(10, "orders")
+	queueDepth.Set(7, "orders")
+	queueDepth.Add(-2, "orders")
+
+	for i := 0; i < 2; i++ {
+		logs := registry.Collect()
+		if len(logs) != 1 {
+			t.Fatalf("Expected 1 log, got %d", len(logs))
+		}
+		if logs[0].metrics["QueueDepth"] != 5.0 {
+			t.Errorf("Expected gauge value 5, got %v", logs[0].metrics["QueueDepth"])
+		}
+	}
+}
+
+func TestHistogramEmitsDistribution(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	latency := registry.Histogram("Latency", UnitMilliseconds, "Service", "Operation")
+
+	latency.Observe(10, "API", "Get")
+	latency.Observe(10, "API", "Get")
+	latency.Observe(30, "API", "Get")
+	latency.Observe(5, "API", "Put")
+
+	logs := registry.Collect()
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 logs, got %d", len(logs))
+	}
+
+	dist, ok := logs[0].metrics["Latency"].(Distribution)
+	if !ok {
+		t.Fatalf("Expected a Distribution, got %T", logs[0].metrics["Latency"])
+	}
+	if len(dist.Values) != 2 || dist.Counts[0] != 2 || dist.Count != 3 || dist.Sum != 50 {
+		t.Errorf("Unexpected distribution: %+v", dist)
+	}
+	if logs[0].metrics["Operation"] != "Get" {
+		t.Errorf("Expected Operation:Get, got %v", logs[0].metrics["Operation"])
+	}
+
+	if logs := registry.Collect(); len(logs) != 0 {
+		t.Errorf("Expected no logs without new observations, got %d", len(logs))
+	}
+}
+
+func TestHistogramSplitsManyValues(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	latency := registry.Histogram("Latency", UnitMilliseconds, "Service")
+	for i := 0; i <= MaxValuesPerMetric; i++ {
+		latency.Observe(float64(i), "API")
+	}
+	latency.Observe(math.NaN(), "API")
+	latency.Observe(math.NaN(), "API")
+
+	logs := registry.Collect()
+	if len(logs) != 2 {
+		t.Fatalf("Expected the values to be split across 2 logs, got %d", len(logs))
+	}
+	var count float64
+	for _, ml := range logs {
+		dist, ok := ml.metrics["Latency"].(Distribution)
+		if !ok || len(dist.Values) > MaxValuesPerMetric {
+			t.Fatalf("Expected distributions of at most %d values, got %v", MaxValuesPerMetric, ml.metrics["Latency"])
+		}
+		if err := ml.Validate(); err != nil {
+			t.Errorf("Expected a valid log, got: %v", err)
+		}
+		count += dist.Count
+	}
+	if count != MaxValuesPerMetric+1 {
+		t.Errorf("Expected every finite observation to be kept, got %v", count)
+	}
+}
+
+func TestRegistryFlushesDimensionlessInstruments(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	requests := registry.Counter("Requests", UnitCount)
+	requests.Inc()
+	registry.Gauge("QueueDepth", UnitCount).Set(3)
+	registry.Histogram("Latency", UnitMilliseconds).Observe(12)
+
+	sink, logs := collectingSink()
+	if err := registry.Flush(sink); err != nil {
+		t.Fatalf("Expected dimensionless instruments to flush, got: %v", err)
+	}
+	if len(logs()) != 1 {
+		t.Fatalf("Expected 1 log, got %d", len(logs()))
+	}
+
+	ml := logs()[0]
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected a valid log, got: %v", err)
+	}
+	if value, _ := ml.Value(SourceDimension); value != SourceValue {
+		t.Errorf("Expected the %s dimension, got %v", SourceDimension, value)
+	}
+	for _, name := range []string{"Requests", "QueueDepth", "Latency"} {
+		if _, exists := ml.Value(name); !exists {
+			t.Errorf("Expected metric %s", name)
+		}
+	}
+}
+
+func TestRegistryGroupsInstrumentsByDimensions(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	requests := registry.Counter("Requests", UnitCount, "Service")
+	errorCount := registry.Counter("Errors", UnitCount, "Service")
+
+	requests.Inc("API")
+	errorCount.Inc("API")
+
+	sink, logs := collectingSink()
+	if err := registry.Flush(sink); err != nil {
+		t.Fatalf("Unexpected flush error: %v", err)
+	}
+
+	emitted := logs()
+	if len(emitted) != 1 {
+		t.Fatalf("Expected both counters in a single log, got %d logs", len(emitted))
+	}
+	if len(emitted[0].emf.Aws.CloudWatchMetrics[0].Metrics) != 2 {
+		t.Errorf("Expected 2 metric definitions, got %d", len(emitted[0].emf.Aws.CloudWatchMetrics[0].Metrics))
+	}
+	if err := emitted[0].Validate(); err != nil {
+		t.Errorf("Expected a valid log, got: %v", err)
+	}
+}
+
+func TestRegistryReturnsExistingInstrument(t *testing.T) {
+	registry := NewRegistry("TestNamespace")
+	first := registry.Counter("Requests", UnitCount, "Service")
+	second := registry.Counter("Requests", UnitCount, "Service")
+
+	first.Inc("API")
+	second.Inc("API")
+
+	logs := registry.Collect()
+	if logs[0].metrics["Requests"] != 2.0 {
+		t.Errorf("Expected both handles to update the same counter, got %v", logs[0].metrics["Requests"])
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{
+			name: "conflicting kind",
+			fn: func(r *Registry) {
+				r.Counter("Requests", UnitCount, "Service")
+				r.Gauge("Requests", UnitCount, "Service")
+			},
+		},
+		{
+			name: "conflicting dimension keys",
+			fn: func(r *Registry) {
+				r.Counter("Requests", UnitCount, "Service")
+				r.Counter("Requests", UnitCount, "Operation")
+			},
+		},
+		{
+			name: "wrong number of dimension values",
+			fn: func(r *Registry) {
+				r.Counter("Requests", UnitCount, "Service").Inc()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic")
+				}
+			}()
+			test.fn(NewRegistry("TestNamespace"))
+		})
+	}
+}
@@ -0,0 +1,250 @@
+// Package localagent emulates the EMF endpoint of the CloudWatch agent for local
+// development and integration tests. It receives EMF events over TCP and UDP,
+// validates them, aggregates the resulting metrics the way CloudWatch does and
+// exposes them through an HTTP query API.
+package localagent
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// DefaultAddr is the address on which the CloudWatch agent listens for EMF events.
+const DefaultAddr = "127.0.0.1:25888"
+
+// maxEventSize is the largest EMF event accepted over TCP.
+const maxEventSize = 1024 * 1024
+
+// Config configures an Agent.
+type Config struct {
+	// TCPAddr is the address to receive newline-delimited events over TCP. Empty disables TCP.
+	TCPAddr string
+	// UDPAddr is the address to receive events over UDP. Empty disables UDP.
+	UDPAddr string
+	// HTTPAddr is the address of the HTTP query API. Empty disables the API.
+	HTTPAddr string
+	// OnEvent is called for every received event with its validation error, if any.
+	// It may be nil.
+	OnEvent func(data []byte, err error)
+	// OnError is called with errors of the HTTP server and of TCP connections, such
+	// as a line longer than the maximum event size. It may be nil.
+	OnError func(error)
+}
+
+// Agent receives EMF events and stores the metrics they produce.
+type Agent struct {
+	config Config
+	store  *Store
+
+	tcpListener  net.Listener
+	udpConn      net.PacketConn
+	httpListener net.Listener
+	httpServer   *http.Server
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New creates a new Agent with the given configuration. Call Start to begin listening.
+func New(config Config) *Agent {
+	return &Agent{
+		config: config,
+		store:  NewStore(),
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Store returns the store that aggregates the received events.
+func (a *Agent) Store() *Store {
+	return a.store
+}
+
+// Start opens the configured listeners and begins receiving events in the background.
+func (a *Agent) Start() error {
+	if a.config.TCPAddr != "" {
+		listener, err := net.Listen("tcp", a.config.TCPAddr)
+		if err != nil {
+			return err
+		}
+		a.tcpListener = listener
+
+		a.wg.Add(1)
+		go a.serveTCP()
+	}
+
+	if a.config.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", a.config.UDPAddr)
+		if err != nil {
+			a.Close()
+			return err
+		}
+		a.udpConn = conn
+
+		a.wg.Add(1)
+		go a.serveUDP()
+	}
+
+	if a.config.HTTPAddr != "" {
+		listener, err := net.Listen("tcp", a.config.HTTPAddr)
+		if err != nil {
+			a.Close()
+			return err
+		}
+		a.httpListener = listener
+		a.httpServer = &http.Server{Handler: a.store.Handler()}
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			if err := a.httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				a.reportError(err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// TCPAddr returns the address of the TCP listener, or nil if TCP is disabled.
+func (a *Agent) TCPAddr() net.Addr {
+	if a.tcpListener == nil {
+		return nil
+	}
+	return a.tcpListener.Addr()
+}
+
+// UDPAddr returns the address of the UDP listener, or nil if UDP is disabled.
+func (a *Agent) UDPAddr() net.Addr {
+	if a.udpConn == nil {
+		return nil
+	}
+	return a.udpConn.LocalAddr()
+}
+
+// HTTPAddr returns the address of the HTTP query API, or nil if the API is disabled.
+func (a *Agent) HTTPAddr() net.Addr {
+	if a.httpListener == nil {
+		return nil
+	}
+	return a.httpListener.Addr()
+}
+
+// Close stops all listeners, closes open connections and waits for them to finish.
+func (a *Agent) Close() error {
+	var errs []error
+	if a.tcpListener != nil {
+		errs = append(errs, a.tcpListener.Close())
+	}
+	if a.udpConn != nil {
+		errs = append(errs, a.udpConn.Close())
+	}
+	if a.httpServer != nil {
+		errs = append(errs, a.httpServer.Close())
+	}
+
+	// Connections accepted from now on are closed right away
+	a.mu.Lock()
+	a.closed = true
+	for conn := range a.conns {
+		conn.Close()
+	}
+	a.mu.Unlock()
+
+	a.wg.Wait()
+
+	var result []error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			result = append(result, err)
+		}
+	}
+	return errors.Join(result...)
+}
+
+// serveTCP accepts TCP connections until the listener is closed.
+func (a *Agent) serveTCP() {
+	defer a.wg.Done()
+
+	for {
+		conn, err := a.tcpListener.Accept()
+		if err != nil {
+			return
+		}
+
+		a.mu.Lock()
+		if a.closed {
+			a.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		a.conns[conn] = struct{}{}
+		a.wg.Add(1)
+		a.mu.Unlock()
+
+		go a.handleConn(conn)
+	}
+}
+
+// handleConn ingests newline-delimited events from a TCP connection.
+func (a *Agent) handleConn(conn net.Conn) {
+	defer a.wg.Done()
+	defer func() {
+		a.mu.Lock()
+		delete(a.conns, conn)
+		a.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		a.ingest(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		a.reportError(fmt.Errorf("connection from %s: %w", conn.RemoteAddr(), err))
+	}
+}
+
+// serveUDP ingests events from UDP datagrams until the connection is closed.
+// A datagram may contain several newline-delimited events.
+func (a *Agent) serveUDP() {
+	defer a.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := a.udpConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			a.ingest(line)
+		}
+	}
+}
+
+// reportError passes an error to the OnError callback.
+func (a *Agent) reportError(err error) {
+	if a.config.OnError != nil {
+		a.config.OnError(err)
+	}
+}
+
+// ingest stores a single event and reports it to the OnEvent callback.
+func (a *Agent) ingest(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	err := a.store.Ingest(line)
+	if a.config.OnEvent != nil {
+		a.config.OnEvent(append([]byte(nil), line...), err)
+	}
+}
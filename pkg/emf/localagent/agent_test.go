@@ -0,0 +1,157 @@
+package localagent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func startAgent(t *testing.T) *Agent {
+	t.Helper()
+
+	agent := New(Config{
+		TCPAddr:  "127.0.0.1:0",
+		UDPAddr:  "127.0.0.1:0",
+		HTTPAddr: "127.0.0.1:0",
+	})
+	if err := agent.Start(); err != nil {
+		t.Fatalf("Failed to start agent: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := agent.Close(); err != nil {
+			t.Errorf("Failed to close agent: %v", err)
+		}
+	})
+	return agent
+}
+
+// waitForEvents waits until the agent has received the given number of events.
+func waitForEvents(t *testing.T, agent *Agent, count int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := agent.Store().Stats()
+		if stats.Accepted+stats.Rejected >= count {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d events, got %+v", count, agent.Store().Stats())
+}
+
+func TestAgentReceivesTCPAndUDP(t *testing.T) {
+	agent := startAgent(t)
+
+	tcp, err := net.Dial("tcp", agent.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect over TCP: %v", err)
+	}
+	defer tcp.Close()
+	tcp.Write(append(marshal(t, requestLog("API", 10)), '\n'))
+	tcp.Write([]byte("{\"invalid\": true}\n"))
+
+	udp, err := net.Dial("udp", agent.UDPAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect over UDP: %v", err)
+	}
+	defer udp.Close()
+	udp.Write(marshal(t, requestLog("Worker", 20)))
+
+	waitForEvents(t, agent, 3)
+
+	stats := agent.Store().Stats()
+	if stats.Accepted != 2 || stats.Rejected != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if series := agent.Store().Query(Query{Dimensions: map[string]string{"Service": "Worker"}}); len(series) != 2 {
+		t.Errorf("Expected 2 series for the UDP event, got %d", len(series))
+	}
+}
+
+func TestAgentReportsOversizedLines(t *testing.T) {
+	errs := make(chan error, 1)
+	agent := New(Config{TCPAddr: "127.0.0.1:0", OnError: func(err error) { errs <- err }})
+	if err := agent.Start(); err != nil {
+		t.Fatalf("Failed to start agent: %v", err)
+	}
+	defer agent.Close()
+
+	tcp, err := net.Dial("tcp", agent.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect over TCP: %v", err)
+	}
+	defer tcp.Close()
+	tcp.Write(append(bytes.Repeat([]byte("x"), maxEventSize+1), '\n'))
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, bufio.ErrTooLong) {
+			t.Errorf("Expected a line too long error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the oversized line to be reported")
+	}
+}
+
+func TestAgentHTTPQuery(t *testing.T) {
+	agent := startAgent(t)
+	agent.Store().Ingest(marshal(t, requestLog("API", 10)))
+	agent.Store().Ingest(marshal(t, requestLog("Worker", 20)))
+
+	base := "http://" + agent.HTTPAddr().String()
+	params := url.Values{
+		"metric":    {"Latency"},
+		"dimension": {"Service:API", "Region:us-west-2"},
+	}
+
+	resp, err := http.Get(base + "/series?" + params.Encode())
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var series []Series
+	if err := json.NewDecoder(resp.Body).Decode(&series); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(series) != 1 || series[0].Datapoints[0].Sum != 10 {
+		t.Errorf("Unexpected series: %+v", series)
+	}
+
+	resp, err = http.Get(base + "/series?dimension=invalid")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a malformed dimension, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, base+"/series", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(base + "/stats")
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var stats Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.Accepted != 0 {
+		t.Errorf("Expected stats to be reset, got %+v", stats)
+	}
+}
@@ -0,0 +1,55 @@
+package localagent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Handler returns the HTTP query API of the store:
+//
+//	GET    /series  lists series, filtered by the namespace, metric and dimension
+//	                query parameters (dimension=Name:Value, repeatable)
+//	DELETE /series  discards all series and statistics
+//	GET    /stats   returns the number of accepted and rejected events
+func (s *Store) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /series", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := Query{
+			Namespace:  params.Get("namespace"),
+			MetricName: params.Get("metric"),
+			Dimensions: make(map[string]string),
+		}
+		for _, dimension := range params["dimension"] {
+			name, value, ok := strings.Cut(dimension, ":")
+			if !ok {
+				http.Error(w, "dimension must be in the form Name:Value", http.StatusBadRequest)
+				return
+			}
+			q.Dimensions[name] = value
+		}
+
+		writeJSON(w, s.Query(q))
+	})
+
+	mux.HandleFunc("DELETE /series", func(w http.ResponseWriter, _ *http.Request) {
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.Stats())
+	})
+
+	return mux
+}
+
+// writeJSON writes value as a JSON response.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,301 @@
+package localagent
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+// Percentiles reported for every datapoint.
+var reportedPercentiles = []float64{50, 90, 99}
+
+// Series is the aggregated data of one CloudWatch metric, identified by its
+// namespace, name and dimensions.
+type Series struct {
+	Namespace         string            `json:"namespace"`
+	MetricName        string            `json:"metricName"`
+	Dimensions        map[string]string `json:"dimensions"`
+	Unit              string            `json:"unit"`
+	StorageResolution int               `json:"storageResolution"`
+	Datapoints        []Datapoint       `json:"datapoints"`
+}
+
+// Datapoint holds the statistics of a series for one period.
+type Datapoint struct {
+	Timestamp   time.Time          `json:"timestamp"`
+	SampleCount float64            `json:"sampleCount"`
+	Sum         float64            `json:"sum"`
+	Minimum     float64            `json:"minimum"`
+	Maximum     float64            `json:"maximum"`
+	Average     float64            `json:"average"`
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+}
+
+// Stats counts the events received by a Store.
+type Stats struct {
+	Accepted int64 `json:"accepted"`
+	Rejected int64 `json:"rejected"`
+}
+
+// Query selects series from a Store. Empty fields match every series.
+type Query struct {
+	Namespace  string
+	MetricName string
+	// Dimensions must all be present with the given values. Series may have additional dimensions.
+	Dimensions map[string]string
+}
+
+// Store validates EMF events and aggregates the metrics they produce per metric,
+// dimension combination and period, the way CloudWatch does.
+type Store struct {
+	mu     sync.Mutex
+	series map[string]*storedSeries
+	stats  Stats
+}
+
+// storedSeries is the mutable state behind a Series.
+type storedSeries struct {
+	namespace  string
+	metricName string
+	dimensions map[string]string
+	unit       string
+	resolution int
+	periods    map[int64]*period
+}
+
+// period holds the samples of a series within one period.
+type period struct {
+	stats  emf.StatisticSet
+	counts map[float64]float64
+}
+
+// NewStore creates a new, empty Store.
+func NewStore() *Store {
+	return &Store{
+		series: make(map[string]*storedSeries),
+	}
+}
+
+// Ingest validates a single EMF document and aggregates the metrics it produces.
+// Invalid events are counted as rejected and their error is returned.
+func (s *Store) Ingest(data []byte) error {
+	ml, err := emf.ParseMetricLog(data)
+	if err == nil {
+		err = ml.Validate()
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.stats.Rejected++
+		s.mu.Unlock()
+		return err
+	}
+
+	datums := emf.ExtractMetrics(ml)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Accepted++
+	for _, datum := range datums {
+		s.add(datum)
+	}
+	return nil
+}
+
+// add aggregates a single datum. The caller must hold s.mu.
+func (s *Store) add(d emf.MetricDatum) {
+	key := d.SeriesKey()
+	series, exists := s.series[key]
+	if !exists {
+		series = &storedSeries{
+			namespace:  d.Namespace,
+			metricName: d.MetricName,
+			dimensions: d.Dimensions,
+			unit:       d.Unit,
+			resolution: d.StorageResolution,
+			periods:    make(map[int64]*period),
+		}
+		s.series[key] = series
+	}
+
+	length := time.Duration(series.resolution) * time.Second
+	start := d.Timestamp.Truncate(length).Unix()
+	p, exists := series.periods[start]
+	if !exists {
+		p = &period{counts: make(map[float64]float64)}
+		series.periods[start] = p
+	}
+
+	for i, value := range d.Values {
+		p.counts[value] += d.Counts[i]
+	}
+	p.stats.Merge(d.Statistics())
+}
+
+// Query returns the series matching q, sorted by namespace, metric name and dimensions.
+func (s *Store) Query(q Query) []Series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.series))
+	for key := range s.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := []Series{}
+	for _, key := range keys {
+		series := s.series[key]
+		if !q.matches(series) {
+			continue
+		}
+		result = append(result, series.snapshot())
+	}
+	return result
+}
+
+// matches reports whether the series satisfies the query.
+func (q Query) matches(series *storedSeries) bool {
+	if q.Namespace != "" && q.Namespace != series.namespace {
+		return false
+	}
+	if q.MetricName != "" && q.MetricName != series.metricName {
+		return false
+	}
+	for key, value := range q.Dimensions {
+		if actual, exists := series.dimensions[key]; !exists || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot returns a copy of the series with its datapoints in chronological order.
+func (series *storedSeries) snapshot() Series {
+	dimensions := make(map[string]string, len(series.dimensions))
+	for key, value := range series.dimensions {
+		dimensions[key] = value
+	}
+
+	starts := make([]int64, 0, len(series.periods))
+	for start := range series.periods {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	datapoints := make([]Datapoint, 0, len(starts))
+	for _, start := range starts {
+		p := series.periods[start]
+		dp := Datapoint{
+			Timestamp:   time.Unix(start, 0).UTC(),
+			SampleCount: p.stats.Count,
+			Sum:         p.stats.Sum,
+			Minimum:     p.stats.Min,
+			Maximum:     p.stats.Max,
+		}
+		if p.stats.Count > 0 {
+			dp.Average = p.stats.Sum / p.stats.Count
+		}
+		if len(p.counts) > 0 {
+			dp.Percentiles = percentiles(p.counts)
+		}
+		datapoints = append(datapoints, dp)
+	}
+
+	return Series{
+		Namespace:         series.namespace,
+		MetricName:        series.metricName,
+		Dimensions:        dimensions,
+		Unit:              series.unit,
+		StorageResolution: series.resolution,
+		Datapoints:        datapoints,
+	}
+}
+
+// percentiles computes the reported percentiles of a value/count distribution
+// using the nearest-rank method.
+func percentiles(counts map[float64]float64) map[string]float64 {
+	values := make([]float64, 0, len(counts))
+	var total float64
+	for value, count := range counts {
+		values = append(values, value)
+		total += count
+	}
+	sort.Float64s(values)
+
+	result := make(map[string]float64, len(reportedPercentiles))
+	for _, p := range reportedPercentiles {
+		rank := math.Ceil(p / 100 * total)
+		var seen float64
+		for _, value := range values {
+			seen += counts[value]
+			if seen >= rank {
+				result[fmt.Sprintf("p%g", p)] = value
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Stats returns the number of accepted and rejected events.
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Reset discards all series and statistics.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series = make(map[string]*storedSeries)
+	s.stats = Stats{}
+}
+
+// WriteSummary writes a table with the overall statistics of every series to w.
+func (s *Store) WriteSummary(w io.Writer) error {
+	stats := s.Stats()
+	if _, err := fmt.Fprintf(w, "Events: %d accepted, %d rejected\n\n", stats.Accepted, stats.Rejected); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tMETRIC\tDIMENSIONS\tUNIT\tCOUNT\tSUM\tMIN\tMAX\tAVG")
+	for _, series := range s.Query(Query{}) {
+		var total emf.StatisticSet
+		for _, dp := range series.Datapoints {
+			total.Merge(emf.StatisticSet{Max: dp.Maximum, Min: dp.Minimum, Count: dp.SampleCount, Sum: dp.Sum})
+		}
+		var average float64
+		if total.Count > 0 {
+			average = total.Sum / total.Count
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%g\t%g\t%g\t%g\t%g\n",
+			series.Namespace, series.MetricName, formatDimensions(series.Dimensions), series.Unit,
+			total.Count, total.Sum, total.Min, total.Max, average)
+	}
+	return tw.Flush()
+}
+
+// formatDimensions renders dimensions as a sorted, comma-separated list of name=value pairs.
+func formatDimensions(dimensions map[string]string) string {
+	pairs := make([]string, 0, len(dimensions))
+	for key, value := range dimensions {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	if len(pairs) == 0 {
+		return "-"
+	}
+	return strings.Join(pairs, ",")
+}
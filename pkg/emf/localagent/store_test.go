@@ -0,0 +1,151 @@
+package localagent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zlatkoc/go-aws-emf/pkg/emf"
+)
+
+func marshal(t *testing.T, ml *emf.MetricLog) []byte {
+	t.Helper()
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Error marshaling to JSON: %v", err)
+	}
+	return data
+}
+
+func requestLog(service string, latency float64) *emf.MetricLog {
+	return emf.NewMetricLog("TestNamespace").Builder().
+		Dimension("Service", service).
+		Dimension("Region", "us-west-2").
+		DimensionSet([]string{"Service"}).
+		DimensionSet([]string{"Service", "Region"}).
+		Metric("Latency", latency, emf.UnitMilliseconds).
+		Build()
+}
+
+func TestStoreAggregatesPerDimensionSet(t *testing.T) {
+	store := NewStore()
+	for _, latency := range []float64{10, 20, 30} {
+		if err := store.Ingest(marshal(t, requestLog("API", latency))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	series := store.Query(Query{MetricName: "Latency"})
+	if len(series) != 2 {
+		t.Fatalf("Expected a series per dimension set, got %d", len(series))
+	}
+
+	for _, s := range series {
+		if len(s.Datapoints) != 1 {
+			t.Fatalf("Expected all samples in one period, got %d datapoints", len(s.Datapoints))
+		}
+		dp := s.Datapoints[0]
+		if dp.SampleCount != 3 || dp.Sum != 60 || dp.Minimum != 10 || dp.Maximum != 30 || dp.Average != 20 {
+			t.Errorf("Unexpected datapoint: %+v", dp)
+		}
+		if dp.Percentiles["p50"] != 20 || dp.Percentiles["p99"] != 30 {
+			t.Errorf("Unexpected percentiles: %v", dp.Percentiles)
+		}
+		if s.Unit != emf.UnitMilliseconds || s.StorageResolution != emf.StorageResolutionStandard {
+			t.Errorf("Unexpected unit or resolution: %s, %d", s.Unit, s.StorageResolution)
+		}
+	}
+
+	filtered := store.Query(Query{Dimensions: map[string]string{"Region": "us-west-2"}})
+	if len(filtered) != 1 || len(filtered[0].Dimensions) != 2 {
+		t.Errorf("Expected only the [Service Region] series, got %+v", filtered)
+	}
+
+	if stats := store.Stats(); stats.Accepted != 3 || stats.Rejected != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestStoreDecodesAggregatedValues(t *testing.T) {
+	store := NewStore()
+	input := `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency"},{"Name":"Size"},{"Name":"Requests"}]}]},` +
+		`"Service":"API","Latency":{"Values":[5,15],"Counts":[3,1],"Max":15,"Min":5,"Count":4,"Sum":30},` +
+		`"Size":{"Max":100,"Min":1,"Count":10,"Sum":500},"Requests":[1,1,1]}`
+
+	if err := store.Ingest([]byte(input)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]Datapoint{
+		"Latency":  {SampleCount: 4, Sum: 30, Minimum: 5, Maximum: 15},
+		"Size":     {SampleCount: 10, Sum: 500, Minimum: 1, Maximum: 100},
+		"Requests": {SampleCount: 3, Sum: 3, Minimum: 1, Maximum: 1},
+	}
+	for name, want := range expected {
+		series := store.Query(Query{MetricName: name})
+		if len(series) != 1 {
+			t.Fatalf("Expected 1 series for %s, got %d", name, len(series))
+		}
+		got := series[0].Datapoints[0]
+		if got.SampleCount != want.SampleCount || got.Sum != want.Sum || got.Minimum != want.Minimum || got.Maximum != want.Maximum {
+			t.Errorf("Unexpected datapoint for %s: %+v", name, got)
+		}
+	}
+}
+
+func TestStoreHighResolutionPeriods(t *testing.T) {
+	store := NewStore()
+	for _, timestamp := range []string{"1600000000000", "1600000000500", "1600000001000"} {
+		input := `{"_aws":{"Timestamp":` + timestamp + `,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency","StorageResolution":1}]}]},"Service":"API","Latency":1}`
+		if err := store.Ingest([]byte(input)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	series := store.Query(Query{})
+	if len(series) != 1 || len(series[0].Datapoints) != 2 {
+		t.Fatalf("Expected 2 one-second datapoints, got %+v", series)
+	}
+	if series[0].Datapoints[0].SampleCount != 2 {
+		t.Errorf("Expected 2 samples in the first second, got %v", series[0].Datapoints[0].SampleCount)
+	}
+}
+
+func TestStoreRejectsInvalidEvents(t *testing.T) {
+	store := NewStore()
+
+	if err := store.Ingest([]byte(`{"not": "emf"}`)); err == nil {
+		t.Error("Expected an error for a non-EMF event")
+	}
+	invalid := `{"_aws":{"Timestamp":1,"CloudWatchMetrics":[{"Namespace":"Test","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency"}]}]},"Latency":1}`
+	if err := store.Ingest([]byte(invalid)); err == nil {
+		t.Error("Expected an error for an invalid event")
+	}
+
+	if stats := store.Stats(); stats.Rejected != 2 || stats.Accepted != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(store.Query(Query{})) != 0 {
+		t.Error("Expected no series from invalid events")
+	}
+}
+
+func TestStoreSummaryAndReset(t *testing.T) {
+	store := NewStore()
+	store.Ingest(marshal(t, requestLog("API", 10)))
+
+	var buf bytes.Buffer
+	if err := store.WriteSummary(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	summary := buf.String()
+	if !strings.Contains(summary, "Events: 1 accepted, 0 rejected") || !strings.Contains(summary, "Region=us-west-2,Service=API") {
+		t.Errorf("Unexpected summary:\n%s", summary)
+	}
+
+	store.Reset()
+	if len(store.Query(Query{})) != 0 || store.Stats().Accepted != 0 {
+		t.Error("Expected an empty store after a reset")
+	}
+}
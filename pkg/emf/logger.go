@@ -0,0 +1,79 @@
+package emf
+
+import (
+	"context"
+	"os"
+)
+
+// Dimension is a dimension name and value pair.
+type Dimension struct {
+	Name  string
+	Value string
+}
+
+// LoggerConfig configures a Logger.
+type LoggerConfig struct {
+	// Namespace is the CloudWatch namespace of every metric log created by the logger.
+	Namespace string
+	// DefaultDimensions are added to every metric log created by the logger and
+	// prepended to each of its dimension sets.
+	DefaultDimensions []Dimension
+	// Sink receives the emitted metric logs. Defaults to a WriterSink on standard output.
+	Sink Sink
+	// ValidationPolicy is the validation policy of every metric log created by the
+	// logger. Defaults to ValidationStrict.
+	ValidationPolicy ValidationPolicy
+	// OnDrop is called for every item dropped from a metric log under ValidationLenient.
+	OnDrop func(Drop)
+	// PreserveOrder keeps the dimension sets and metric definitions of every metric
+	// log created by the logger in declaration order instead of sorting them.
+	PreserveOrder bool
+	// XRayTraceID adds the xray_trace_id property to metric logs created by
+	// NewMetricLogContext, in addition to the TraceId property.
+	XRayTraceID bool
+}
+
+// Logger creates metric logs that share a namespace and default dimensions,
+// and emits them to a sink.
+type Logger struct {
+	config LoggerConfig
+}
+
+// NewLogger creates a new Logger with the given configuration.
+func NewLogger(config LoggerConfig) *Logger {
+	if config.Sink == nil {
+		config.Sink = NewWriterSink(os.Stdout)
+	}
+
+	return &Logger{
+		config: config,
+	}
+}
+
+// NewMetricLog creates a new metric log in the logger's namespace with its default dimensions.
+// Use SetDimensions on the returned log to opt out of the default dimensions.
+func (l *Logger) NewMetricLog() *MetricLog {
+	ml := NewMetricLog(l.config.Namespace)
+	ml.SetValidationPolicy(l.config.ValidationPolicy, l.config.OnDrop)
+	ml.SetPreserveOrder(l.config.PreserveOrder)
+	for _, dim := range l.config.DefaultDimensions {
+		ml.PutDefaultDimension(dim.Name, dim.Value)
+	}
+	return ml
+}
+
+// NewMetricLogContext creates a new metric log like NewMetricLog and adds the trace
+// of ctx, if any, as properties.
+func (l *Logger) NewMetricLogContext(ctx context.Context) *MetricLog {
+	ml := l.NewMetricLog()
+	ml.PutTraceContext(ctx)
+	if l.config.XRayTraceID {
+		ml.PutXRayTraceContext(ctx)
+	}
+	return ml
+}
+
+// Emit sends the metric log to the logger's sink.
+func (l *Logger) Emit(ml *MetricLog) error {
+	return l.config.Sink.Emit(ml)
+}
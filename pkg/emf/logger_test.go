@@ -0,0 +1,119 @@
+package emf
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// dimensionSetsOf marshals the metric log and returns its serialized dimension sets.
+func dimensionSetsOf(t *testing.T, ml *MetricLog) [][]string {
+	t.Helper()
+
+	jsonData, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Error marshaling to JSON: %v", err)
+	}
+
+	var parsed EmfFormatJson
+	if err := json.Unmarshal(jsonData, &parsed); err != nil {
+		t.Fatalf("Error parsing JSON: %v", err)
+	}
+	return parsed.Aws.CloudWatchMetrics[0].Dimensions
+}
+
+func TestDefaultDimensionsArePrepended(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("ServiceName", "UserService")
+	ml.PutDefaultDimension("Environment", "Production")
+	ml.PutDimension("Operation", "GetUser")
+	ml.WithDimensionSet([]string{"Operation"})
+	ml.WithDimensionSet([]string{"Environment", "Operation"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+	// Both sets resolve to the same dimensions and are serialized once.
+	expected := [][]string{
+		{"ServiceName", "Environment", "Operation"},
+	}
+	if dims := dimensionSetsOf(t, ml); !reflect.DeepEqual(dims, expected) {
+		t.Errorf("Expected dimension sets %v, got %v", expected, dims)
+	}
+
+	// The dimension sets of the log itself are left untouched.
+	if len(ml.emf.Aws.CloudWatchMetrics[0].Dimensions[0]) != 1 {
+		t.Errorf("Expected the stored dimension set to be unchanged, got %v", ml.emf.Aws.CloudWatchMetrics[0].Dimensions[0])
+	}
+}
+
+func TestDefaultDimensionsWithoutDimensionSets(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("ServiceName", "UserService")
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+	if err := ml.Validate(); err != nil {
+		t.Fatalf("Expected default dimensions to satisfy validation, got: %v", err)
+	}
+
+	expected := [][]string{{"ServiceName"}}
+	if dims := dimensionSetsOf(t, ml); !reflect.DeepEqual(dims, expected) {
+		t.Errorf("Expected dimension sets %v, got %v", expected, dims)
+	}
+}
+
+func TestDefaultDimensionValuePrecedence(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("ServiceName", "Explicit")
+	ml.PutDefaultDimension("ServiceName", "Default")
+
+	if ml.metrics["ServiceName"] != "Explicit" {
+		t.Errorf("Expected an explicit value to take precedence, got %v", ml.metrics["ServiceName"])
+	}
+
+	ml.PutDimension("ServiceName", "Override")
+	if ml.metrics["ServiceName"] != "Override" {
+		t.Errorf("Expected PutDimension to override the default value, got %v", ml.metrics["ServiceName"])
+	}
+}
+
+func TestSetDimensionsOptsOutOfDefaults(t *testing.T) {
+	ml := NewMetricLog("TestNamespace").Builder().
+		DefaultDimension("ServiceName", "UserService").
+		Dimension("Operation", "GetUser").
+		DimensionSet([]string{"ServiceName"}).
+		SetDimensions([]string{"Operation"}).
+		Metric("Latency", 42.0, UnitMilliseconds).
+		Build()
+
+	expected := [][]string{{"Operation"}}
+	if dims := dimensionSetsOf(t, ml); !reflect.DeepEqual(dims, expected) {
+		t.Errorf("Expected dimension sets %v, got %v", expected, dims)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	sink, logs := collectingSink()
+	logger := NewLogger(LoggerConfig{
+		Namespace: "TestNamespace",
+		DefaultDimensions: []Dimension{
+			{Name: "ServiceName", Value: "UserService"},
+		},
+		Sink: sink,
+	})
+
+	ml := logger.NewMetricLog()
+	ml.PutDimension("Operation", "GetUser")
+	ml.WithDimensionSet([]string{"Operation"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+	if err := logger.Emit(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(logs()) != 1 {
+		t.Fatalf("Expected 1 emitted log, got %d", len(logs()))
+	}
+
+	expected := [][]string{{"ServiceName", "Operation"}}
+	if dims := dimensionSetsOf(t, logs()[0]); !reflect.DeepEqual(dims, expected) {
+		t.Errorf("Expected dimension sets %v, got %v", expected, dims)
+	}
+}
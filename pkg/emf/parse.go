@@ -0,0 +1,46 @@
+package emf
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ParseMetricLog parses an EMF document into a MetricLog. The document is decoded
+// but not validated; call Validate on the result to check it against the EMF spec.
+func ParseMetricLog(data []byte) (*MetricLog, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	raw, exists := fields["_aws"]
+	if !exists {
+		return nil, fmt.Errorf("missing _aws metadata")
+	}
+
+	var aws EmfFormatJsonAws
+	if err := json.Unmarshal(raw, &aws); err != nil {
+		return nil, fmt.Errorf("invalid _aws metadata: %w", err)
+	}
+	if len(aws.CloudWatchMetrics) == 0 {
+		return nil, fmt.Errorf("_aws metadata must contain at least one metric directive")
+	}
+
+	ml := &MetricLog{
+		emf:     EmfFormatJson{Aws: aws},
+		metrics: make(map[string]interface{}, len(fields)-1),
+	}
+	for key, value := range fields {
+		if key == "_aws" {
+			continue
+		}
+
+		var decoded interface{}
+		if err := json.Unmarshal(value, &decoded); err != nil {
+			return nil, fmt.Errorf("invalid value for '%s': %w", key, err)
+		}
+		ml.metrics[key] = decoded
+	}
+
+	return ml, nil
+}
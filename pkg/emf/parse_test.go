@@ -0,0 +1,104 @@
+package emf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMetricLogRoundTrip(t *testing.T) {
+	original := newRequestLog("API", 42)
+	original.Builder().Property("RequestId", "req-123")
+
+	jsonData, err := original.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Error marshaling to JSON: %v", err)
+	}
+
+	parsed, err := ParseMetricLog(jsonData)
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if err := parsed.Validate(); err != nil {
+		t.Errorf("Expected the parsed log to be valid, got: %v", err)
+	}
+
+	if parsed.emf.Aws.Timestamp != original.emf.Aws.Timestamp {
+		t.Errorf("Expected timestamp %d, got %d", original.emf.Aws.Timestamp, parsed.emf.Aws.Timestamp)
+	}
+	if parsed.emf.Aws.CloudWatchMetrics[0].Namespace != "TestNamespace" {
+		t.Errorf("Expected namespace TestNamespace, got %s", parsed.emf.Aws.CloudWatchMetrics[0].Namespace)
+	}
+	if parsed.metrics["Latency"] != 42.0 || parsed.metrics["Service"] != "API" || parsed.metrics["RequestId"] != "req-123" {
+		t.Errorf("Unexpected values: %v", parsed.metrics)
+	}
+}
+
+func TestParseMetricLogErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		errorContains string
+	}{
+		{
+			name:          "invalid JSON",
+			input:         `{"_aws":`,
+			errorContains: "invalid JSON",
+		},
+		{
+			name:          "not an object",
+			input:         `[1, 2, 3]`,
+			errorContains: "invalid JSON",
+		},
+		{
+			name:          "missing metadata",
+			input:         `{"Latency": 42}`,
+			errorContains: "missing _aws",
+		},
+		{
+			name:          "missing timestamp",
+			input:         `{"_aws": {"CloudWatchMetrics": []}}`,
+			errorContains: "Timestamp",
+		},
+		{
+			name:          "no directives",
+			input:         `{"_aws": {"Timestamp": 1, "CloudWatchMetrics": []}}`,
+			errorContains: "at least one metric directive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseMetricLog([]byte(test.input))
+			if err == nil {
+				t.Fatal("Expected a parse error, got nil")
+			}
+			if !strings.Contains(err.Error(), test.errorContains) {
+				t.Errorf("Expected error to contain '%s', got: %v", test.errorContains, err)
+			}
+		})
+	}
+}
+
+func TestValidateAllDirectives(t *testing.T) {
+	input := `{
+		"_aws": {
+			"Timestamp": 1600000000000,
+			"CloudWatchMetrics": [
+				{"Namespace": "First", "Dimensions": [["Service"]], "Metrics": [{"Name": "Latency"}]},
+				{"Namespace": "Second", "Dimensions": [["Service"]], "Metrics": [{"Name": "Missing"}]}
+			]
+		},
+		"Service": "API",
+		"Latency": 42
+	}`
+
+	ml, err := ParseMetricLog([]byte(input))
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	err = ml.Validate()
+	if err == nil || !strings.Contains(err.Error(), "'Missing'") {
+		t.Errorf("Expected the second directive to be validated, got: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package emf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationPolicy controls how MarshalJSON treats a metric log that fails validation.
+type ValidationPolicy int
+
+// Validation policies
+const (
+	// ValidationStrict fails marshaling if the log is invalid. It is the default.
+	ValidationStrict ValidationPolicy = iota
+	// ValidationLenient drops invalid metric definitions, dimensions and dimension
+	// sets and marshals the rest. Marshaling only fails if nothing publishable is
+	// left, such as when the namespace is invalid or no metric remains.
+	ValidationLenient
+	// ValidationOff marshals the log without validating it.
+	ValidationOff
+)
+
+// String returns the name of the policy.
+func (p ValidationPolicy) String() string {
+	switch p {
+	case ValidationStrict:
+		return "strict"
+	case ValidationLenient:
+		return "lenient"
+	case ValidationOff:
+		return "off"
+	default:
+		return fmt.Sprintf("ValidationPolicy(%d)", int(p))
+	}
+}
+
+// DropKind is the kind of item dropped from a metric log.
+type DropKind string
+
+// Drop kinds
+const (
+	DropMetric       DropKind = "metric"
+	DropDimension    DropKind = "dimension"
+	DropDimensionSet DropKind = "dimensionSet"
+)
+
+// Drop describes an item removed from a metric log under ValidationLenient.
+// Dropped metrics lose their definition, not their value, so the value remains
+// in the log event as a property.
+type Drop struct {
+	Kind DropKind
+	// Name is the metric or dimension name, or the dimensions of the dimension set joined by commas.
+	Name string
+	// Err is the validation error that caused the drop.
+	Err error
+}
+
+// String returns a description of the drop.
+func (d Drop) String() string {
+	return fmt.Sprintf("dropped %s '%s': %v", d.Kind, d.Name, d.Err)
+}
+
+// SetValidationPolicy sets the validation policy of the log. onDrop, which may be
+// nil, is called for every item dropped under ValidationLenient, each time the
+// log is marshaled.
+func (ml *MetricLog) SetValidationPolicy(policy ValidationPolicy, onDrop func(Drop)) *MetricLog {
+	ml.policy = policy
+	ml.onDrop = onDrop
+	return ml
+}
+
+// ValidationPolicy returns the validation policy of the log.
+func (ml *MetricLog) ValidationPolicy() ValidationPolicy {
+	return ml.policy
+}
+
+// dropInvalid returns a copy of the log as it will be serialized, without the
+// metric definitions, dimensions and dimension sets that fail validation. It
+// returns an error if the remaining log is still invalid.
+func (ml *MetricLog) dropInvalid() (*MetricLog, error) {
+	resolved := *ml.withDefaults()
+	// The default dimensions are applied, and may be dropped below
+	resolved.replaceDefaults = true
+	directives := make([]EmfFormatJsonAwsCloudWatchMetricsElem, len(resolved.emf.Aws.CloudWatchMetrics))
+
+	var drops []Drop
+	for i, directive := range resolved.emf.Aws.CloudWatchMetrics {
+		var metrics []EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem
+		for _, metric := range directive.Metrics {
+			if err := resolved.validateMetric(metric); err != nil {
+				drops = append(drops, Drop{Kind: DropMetric, Name: metric.Name, Err: err})
+				continue
+			}
+			metrics = append(metrics, metric)
+		}
+
+		var dimensionSets [][]string
+		invalid := make(map[string]bool)
+		for _, dimSet := range directive.Dimensions {
+			if err := ml.validateDimensionSetSize(dimSet); err != nil {
+				err = fmt.Errorf("dimension set %v", err)
+				drops = append(drops, Drop{Kind: DropDimensionSet, Name: strings.Join(dimSet, ","), Err: err})
+				continue
+			}
+
+			var valid []string
+			seen := make(map[string]bool, len(dimSet))
+			for _, dim := range dimSet {
+				if seen[dim] {
+					err := fmt.Errorf("dimension set contains dimension '%s' more than once", dim)
+					drops = append(drops, Drop{Kind: DropDimension, Name: dim, Err: err})
+					continue
+				}
+				seen[dim] = true
+
+				if err := resolved.validateDimension(dim); err != nil {
+					if !invalid[dim] {
+						invalid[dim] = true
+						drops = append(drops, Drop{Kind: DropDimension, Name: dim, Err: err})
+					}
+					continue
+				}
+				valid = append(valid, dim)
+			}
+			if len(valid) == 0 {
+				err := fmt.Errorf("dimension set contains no valid dimension")
+				drops = append(drops, Drop{Kind: DropDimensionSet, Name: strings.Join(dimSet, ","), Err: err})
+				continue
+			}
+			dimensionSets = append(dimensionSets, valid)
+		}
+
+		directive.Metrics = metrics
+		directive.Dimensions = dimensionSets
+		directives[i] = directive
+	}
+	resolved.emf.Aws.CloudWatchMetrics = directives
+
+	if ml.onDrop != nil {
+		for _, drop := range drops {
+			ml.onDrop(drop)
+		}
+	}
+
+	if err := resolved.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Deduplicate and order the remaining dimension sets and metric definitions
+	var defaults []string
+	if !ml.replaceDefaults {
+		defaults = ml.defaultDimensions
+	}
+	for i := range directives {
+		directives[i].Dimensions = canonicalDimensionSets(directives[i].Dimensions, defaults, ml.preserveOrder)
+		directives[i].Metrics = canonicalMetrics(directives[i].Metrics, ml.preserveOrder)
+	}
+	return &resolved, nil
+}
@@ -0,0 +1,148 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// invalidLog returns a log with an invalid unit, a metric without a value and a
+// dimension without a value next to valid metrics and dimensions.
+func invalidLog() *MetricLog {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service", "Missing"})
+	ml.WithDimensionSet([]string{"Missing"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	ml.PutMetric("Size", 10, "Hours")
+	ml.emf.Aws.CloudWatchMetrics[0].Metrics = append(ml.emf.Aws.CloudWatchMetrics[0].Metrics,
+		EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem{Name: "NoValue"})
+	return ml
+}
+
+func TestValidationStrict(t *testing.T) {
+	if _, err := invalidLog().MarshalJSON(); err == nil {
+		t.Error("Expected the strict policy to fail")
+	}
+}
+
+func TestValidationLenient(t *testing.T) {
+	var drops []Drop
+	ml := invalidLog().SetValidationPolicy(ValidationLenient, func(d Drop) {
+		drops = append(drops, d)
+	})
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Expected the lenient policy to succeed, got: %v", err)
+	}
+	if err := ValidateJSON(data); err != nil {
+		t.Errorf("Expected a valid document, got: %v", err)
+	}
+
+	var event struct {
+		Aws EmfFormatJsonAws `json:"_aws"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	directive := event.Aws.CloudWatchMetrics[0]
+	if len(directive.Metrics) != 1 || directive.Metrics[0].Name != "Latency" {
+		t.Errorf("Expected only the valid metric, got %+v", directive.Metrics)
+	}
+	if len(directive.Dimensions) != 1 || strings.Join(directive.Dimensions[0], ",") != "Service" {
+		t.Errorf("Expected the dimension without a value to be dropped, got %v", directive.Dimensions)
+	}
+	if !bytes.Contains(data, []byte(`"Size":10`)) {
+		t.Errorf("Expected the value of the dropped metric to be kept, got %s", data)
+	}
+
+	expected := []string{
+		"dropped metric 'Size'",
+		"dropped metric 'NoValue'",
+		"dropped dimension 'Missing'",
+		"dropped dimensionSet 'Missing'",
+	}
+	if len(drops) != len(expected) {
+		t.Fatalf("Expected %d drops, got %v", len(expected), drops)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(drops[i].String(), prefix) {
+			t.Errorf("Expected drop %q, got %q", prefix, drops[i])
+		}
+	}
+
+	// The log itself is left unchanged
+	if len(ml.emf.Aws.CloudWatchMetrics[0].Metrics) != 3 {
+		t.Error("Expected the metric log not to be modified")
+	}
+}
+
+func TestValidationLenientNothingLeft(t *testing.T) {
+	ml := NewMetricLog("TestNamespace").SetValidationPolicy(ValidationLenient, nil)
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Size", 10, "Hours")
+
+	if _, err := ml.MarshalJSON(); err == nil {
+		t.Error("Expected an error when no valid metric is left")
+	}
+}
+
+func TestValidationOff(t *testing.T) {
+	ml := invalidLog().SetValidationPolicy(ValidationOff, nil)
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Expected no validation, got: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"Hours"`)) {
+		t.Errorf("Expected the log to be marshaled as is, got %s", data)
+	}
+}
+
+func TestLoggerValidationPolicy(t *testing.T) {
+	var buf bytes.Buffer
+	var drops []Drop
+	logger := NewLogger(LoggerConfig{
+		Namespace:         "TestNamespace",
+		DefaultDimensions: []Dimension{{Name: "Service", Value: "API"}},
+		Sink:              NewWriterSink(&buf),
+		ValidationPolicy:  ValidationLenient,
+		OnDrop:            func(d Drop) { drops = append(drops, d) },
+	})
+
+	ml := logger.NewMetricLog()
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	ml.PutMetric("Size", 10, "Hours")
+	if err := logger.Emit(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(drops) != 1 || drops[0].Kind != DropMetric || drops[0].Name != "Size" {
+		t.Errorf("Expected the invalid metric to be reported, got %v", drops)
+	}
+	if !strings.Contains(buf.String(), `"Dimensions":[["Service"]]`) {
+		t.Errorf("Expected the default dimensions to be applied, got %s", buf.String())
+	}
+}
+
+func TestValidationLenientDuplicateDimension(t *testing.T) {
+	var drops []Drop
+	ml := NewMetricLog("TestNamespace").SetValidationPolicy(ValidationLenient, func(d Drop) {
+		drops = append(drops, d)
+	})
+	ml.PutDimension("Service", "API")
+	ml.WithDimensionSet([]string{"Service", "Service"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"Dimensions":[["Service"]]`)) {
+		t.Errorf("Expected the repeated dimension to be dropped, got %s", data)
+	}
+	if len(drops) != 1 || drops[0].Kind != DropDimension {
+		t.Errorf("Expected the repeated dimension to be reported, got %v", drops)
+	}
+}
@@ -0,0 +1,170 @@
+package emf
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event limits
+const (
+	// MaxEventSize is the maximum size in bytes of a serialized log event. The
+	// CloudWatch Logs event size limit is 256 KiB, including 26 bytes of overhead.
+	MaxEventSize = 256*1024 - 26
+	// TruncatedSuffix marks a property value that was truncated to fit the event
+	// into MaxEventSize.
+	TruncatedSuffix = "...[truncated]"
+)
+
+// Property keys of the property helpers
+const (
+	PropertyRequestID = "RequestId"
+	PropertyTraceID   = "TraceId"
+)
+
+// ErrorProperty is the serialized form of an error property.
+type ErrorProperty struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	// Chain lists the errors wrapped by the error, depth first.
+	Chain []ErrorProperty `json:"chain,omitempty"`
+}
+
+// PutProperty adds a property to the log. Properties are not reported as metrics
+// but appear in the log events. If the serialized event is larger than
+// MaxEventSize, the largest properties are truncated when the log is marshaled.
+func (ml *MetricLog) PutProperty(key string, value interface{}) *MetricLog {
+	ml.metrics[key] = value
+	return ml
+}
+
+// PutObject adds a structured property, serialized to JSON when it is added so that
+// later changes to v do not affect the log. Values that cannot be serialized are
+// kept as they are and make MarshalJSON fail.
+func (ml *MetricLog) PutObject(key string, v interface{}) *MetricLog {
+	data, err := json.Marshal(v)
+	if err != nil {
+		ml.metrics[key] = v
+		return ml
+	}
+	ml.metrics[key] = json.RawMessage(data)
+	return ml
+}
+
+// PutError adds an error property with the message and type of the error and
+// of every error it wraps. A nil error is ignored.
+func (ml *MetricLog) PutError(key string, err error) *MetricLog {
+	if err == nil {
+		return ml
+	}
+	property := ErrorProperty{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		Chain:   wrappedErrors(err, nil),
+	}
+	return ml.PutObject(key, property)
+}
+
+// wrappedErrors appends the errors wrapped by err, depth first, to chain.
+// Both single errors and the joined errors of errors.Join are followed.
+func wrappedErrors(err error, chain []ErrorProperty) []ErrorProperty {
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			wrapped = []error{inner}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+
+	for _, inner := range wrapped {
+		if inner == nil {
+			continue
+		}
+		chain = append(chain, ErrorProperty{Message: inner.Error(), Type: fmt.Sprintf("%T", inner)})
+		chain = wrappedErrors(inner, chain)
+	}
+	return chain
+}
+
+// PutRequestID adds the request ID as the RequestId property.
+func (ml *MetricLog) PutRequestID(id string) *MetricLog {
+	return ml.PutProperty(PropertyRequestID, id)
+}
+
+// PutTraceID adds the trace ID as the TraceId property.
+func (ml *MetricLog) PutTraceID(id string) *MetricLog {
+	return ml.PutProperty(PropertyTraceID, id)
+}
+
+// PutTime adds a time property in RFC 3339 format in UTC, with fractional seconds
+// if the time has any.
+func (ml *MetricLog) PutTime(key string, t time.Time) *MetricLog {
+	ml.metrics[key] = t.UTC().Format(time.RFC3339Nano)
+	return ml
+}
+
+// limitEvent returns the serialized event of the fields, with its largest
+// properties truncated until it fits into MaxEventSize. String properties are
+// truncated; other properties, such as objects, are dropped so that no property
+// changes its type. Properties are the fields not referenced by a metric definition
+// or dimension set of the metadata. The fields are modified in place.
+func limitEvent(fields map[string]interface{}, data []byte, metadata EmfFormatJsonAws) ([]byte, error) {
+	referenced := make(map[string]bool)
+	for _, directive := range metadata.CloudWatchMetrics {
+		for _, metric := range directive.Metrics {
+			referenced[metric.Name] = true
+		}
+		for _, dimSet := range directive.Dimensions {
+			for _, dim := range dimSet {
+				referenced[dim] = true
+			}
+		}
+	}
+
+	for len(data) > MaxEventSize {
+		// Find the largest property
+		var largest string
+		size := 0
+		for key, value := range fields {
+			if key == "_aws" || referenced[key] {
+				continue
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			if len(encoded) > size {
+				largest, size = key, len(encoded)
+			}
+		}
+		if largest == "" {
+			return nil, fmt.Errorf("log event of %d bytes exceeds the maximum of %d bytes", len(data), MaxEventSize)
+		}
+
+		text, isString := fields[largest].(string)
+		switch {
+		case !isString:
+			// Truncating an object, array or number would turn it into a string
+			delete(fields, largest)
+		case len(text) <= len(TruncatedSuffix):
+			// Only properties longer than the suffix can shrink
+			return nil, fmt.Errorf("log event of %d bytes exceeds the maximum of %d bytes", len(data), MaxEventSize)
+		default:
+			// Removing the excess bytes from its text, and as many for the suffix that
+			// replaces them, shrinks the serialized event by at least the excess
+			keep := len(text) - (len(data) - MaxEventSize) - len(TruncatedSuffix)
+			if keep < 0 {
+				keep = 0
+			}
+			fields[largest] = truncate(text, keep) + TruncatedSuffix
+		}
+
+		var err error
+		if data, err = json.Marshal(fields); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
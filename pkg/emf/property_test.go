@@ -0,0 +1,136 @@
+package emf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPropertyHelpers(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.Builder().
+		Dimension("Service", "API").
+		DimensionSet([]string{"Service"}).
+		Metric("Latency", 42.0, UnitMilliseconds).
+		Object("Request", map[string]interface{}{"method": "GET", "path": "/users"}).
+		RequestID("req-123").
+		TraceID("trace-456").
+		Time("StartedAt", time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)))
+
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(ml.String()), &event); err != nil {
+		t.Fatalf("Failed to unmarshal the log: %v", err)
+	}
+
+	request, isObject := event["Request"].(map[string]interface{})
+	if !isObject || request["method"] != "GET" || request["path"] != "/users" {
+		t.Errorf("Expected the Request object, got %v", event["Request"])
+	}
+	if event[PropertyRequestID] != "req-123" {
+		t.Errorf("Expected RequestId req-123, got %v", event[PropertyRequestID])
+	}
+	if event[PropertyTraceID] != "trace-456" {
+		t.Errorf("Expected TraceId trace-456, got %v", event[PropertyTraceID])
+	}
+	if event["StartedAt"] != "2024-01-02T02:04:05Z" {
+		t.Errorf("Expected StartedAt in RFC 3339 UTC, got %v", event["StartedAt"])
+	}
+}
+
+func TestPutError(t *testing.T) {
+	base := &fs.PathError{Op: "open", Path: "config.yaml", Err: fs.ErrNotExist}
+	err := fmt.Errorf("loading config: %w", errors.Join(base, errors.New("fallback failed")))
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutError("Error", err)
+	ml.PutError("NilError", nil)
+
+	if _, exists := ml.Value("NilError"); exists {
+		t.Error("Expected a nil error to be ignored")
+	}
+
+	value, _ := ml.Value("Error")
+	data, _ := json.Marshal(value)
+	var property ErrorProperty
+	if err := json.Unmarshal(data, &property); err != nil {
+		t.Fatalf("Failed to unmarshal the error property: %v", err)
+	}
+
+	if property.Message != err.Error() || property.Type != "*fmt.wrapError" {
+		t.Errorf("Expected the message and type of the error, got %+v", property)
+	}
+
+	var types []string
+	for _, wrapped := range property.Chain {
+		types = append(types, wrapped.Type)
+	}
+	expected := "*errors.joinError *fs.PathError *errors.errorString *errors.errorString"
+	if got := strings.Join(types, " "); got != expected {
+		t.Errorf("Expected chain %s, got %s", expected, got)
+	}
+}
+
+func TestEventSizeLimit(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", strings.Repeat("s", MaxDimensionValueLength))
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	ml.PutProperty("Small", "value")
+	ml.PutProperty("Large", strings.Repeat("é", MaxEventSize))
+	ml.PutObject("LargeObject", map[string]string{"data": strings.Repeat("x", MaxEventSize/2)})
+
+	// Properties are stored as they are and only limited when marshaled
+	if value, _ := ml.Value("Large"); len(value.(string)) != 2*MaxEventSize {
+		t.Errorf("Expected the property to be stored untruncated, got %d bytes", len(value.(string)))
+	}
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(data) > MaxEventSize {
+		t.Errorf("Expected the event to fit into %d bytes, got %d bytes", MaxEventSize, len(data))
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+	if event["Small"] != "value" || event["Latency"] != 42.0 || event["Service"] != strings.Repeat("s", MaxDimensionValueLength) {
+		t.Errorf("Expected the metrics, dimensions and small properties to be kept, got %v", event["Small"])
+	}
+	large, _ := event["Large"].(string)
+	if !strings.HasSuffix(large, TruncatedSuffix) || !strings.HasSuffix(strings.TrimSuffix(large, TruncatedSuffix), "é") {
+		t.Errorf("Expected Large to be truncated at a character boundary")
+	}
+
+	if _, ok := event["LargeObject"].(map[string]interface{}); !ok {
+		t.Errorf("Expected LargeObject to be kept as an object, got %T", event["LargeObject"])
+	}
+
+	// Objects are dropped instead of being truncated to strings
+	ml.PutObject("Huge", map[string]string{"data": strings.Repeat("x", MaxEventSize)})
+	if data, err = ml.MarshalJSON(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	event = nil
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+	if _, exists := event["Huge"]; exists || len(data) > MaxEventSize {
+		t.Errorf("Expected Huge to be dropped, got %d bytes", len(data))
+	}
+	if _, ok := event["LargeObject"].(map[string]interface{}); !ok {
+		t.Errorf("Expected LargeObject to be kept as an object, got %T", event["LargeObject"])
+	}
+
+	ml.PutMetric("Size", strings.Repeat("1", MaxEventSize), UnitBytes)
+	ml.SetValidationPolicy(ValidationOff, nil)
+	if _, err := ml.MarshalJSON(); err == nil || !strings.Contains(err.Error(), "exceeds the maximum") {
+		t.Errorf("Expected an error when only metrics exceed the limit, got %v", err)
+	}
+}
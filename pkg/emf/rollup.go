@@ -0,0 +1,204 @@
+package emf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rollup limits
+const (
+	// RollupWarningThreshold is the number of generated dimension sets above which
+	// MetricLogBuilder.Rollup reports a RollupWarning. Every dimension set multiplies
+	// the number of custom metrics a metric log creates.
+	RollupWarningThreshold = 30
+	// MaxRollupDimensionSets is the maximum number of dimension sets a rollup generates.
+	MaxRollupDimensionSets = 1024
+)
+
+// RollupStrategy selects the dimension sets generated for a list of dimensions.
+type RollupStrategy int
+
+const (
+	// RollupNone generates a single set of all dimensions.
+	RollupNone RollupStrategy = iota
+	// RollupPerDimension generates one set per dimension, followed by the set of all dimensions.
+	RollupPerDimension
+	// RollupAll generates every non-empty subset of the dimensions, the power set,
+	// from the smallest to the largest.
+	RollupAll
+)
+
+// RollupWarning describes a rollup that generated more than RollupWarningThreshold
+// dimension sets, or skipped some of its dimension sets.
+type RollupWarning struct {
+	// Dimensions are the dimensions of the rollup.
+	Dimensions []string
+	// Sets is the number of dimension sets generated.
+	Sets int
+	// Truncated reports whether the rollup skipped dimension sets, because they
+	// exceeded MaxDimensionSetSize or the rollup stopped at MaxRollupDimensionSets.
+	Truncated bool
+}
+
+// String returns a description of the warning.
+func (w RollupWarning) String() string {
+	if w.Truncated {
+		return fmt.Sprintf("rollup of %d dimensions [%s] truncated to %d dimension sets",
+			len(w.Dimensions), strings.Join(w.Dimensions, ", "), w.Sets)
+	}
+	return fmt.Sprintf("rollup of %d dimensions [%s] generated %d dimension sets",
+		len(w.Dimensions), strings.Join(w.Dimensions, ", "), w.Sets)
+}
+
+// OnRollupWarning sets the function called when a later Rollup on the builder
+// generates more than RollupWarningThreshold dimension sets, or skips some of its
+// dimension sets. Without it, rollups are not reported.
+func (b *MetricLogBuilder) OnRollupWarning(onWarning func(RollupWarning)) *MetricLogBuilder {
+	b.onRollupWarning = onWarning
+	return b
+}
+
+// RollupDimensionSets returns the dimension sets the strategy generates for the
+// dimensions. Sets larger than MaxDimensionSetSize are skipped, and at most
+// MaxRollupDimensionSets sets are generated.
+func RollupDimensionSets(strategy RollupStrategy, dimensions []string) [][]string {
+	sets, _ := rollup(strategy, dimensions, nil)
+	return sets
+}
+
+// Rollup adds the dimension sets the strategy generates for the dimensions, which
+// must have values in the log. The default dimensions of the log that a set does
+// not already contain count towards MaxDimensionSetSize, so larger sets are
+// skipped. The builder's OnRollupWarning function is called if the rollup skips
+// sets or generates more than RollupWarningThreshold sets.
+func (b *MetricLogBuilder) Rollup(strategy RollupStrategy, dimensions ...string) *MetricLogBuilder {
+	var defaults []string
+	if !b.metricLog.replaceDefaults {
+		defaults = b.metricLog.defaultDimensions
+	}
+
+	sets, truncated := rollup(strategy, dimensions, defaults)
+	if (truncated || len(sets) > RollupWarningThreshold) && b.onRollupWarning != nil {
+		b.onRollupWarning(RollupWarning{Dimensions: dimensions, Sets: len(sets), Truncated: truncated})
+	}
+
+	for _, dimSet := range sets {
+		b.metricLog.WithDimensionSet(dimSet)
+	}
+	return b
+}
+
+// rollup generates the dimension sets of a strategy that, together with the
+// default dimensions they do not contain, have at most MaxDimensionSetSize
+// dimensions. It reports whether it skipped any set of the strategy.
+func rollup(strategy RollupStrategy, dimensions, defaults []string) ([][]string, bool) {
+	dimensions = uniqueDimensions(dimensions)
+	if len(dimensions) == 0 {
+		return nil, false
+	}
+
+	fits := func(dimSet []string) bool {
+		size := len(dimSet)
+		for _, def := range defaults {
+			if !containsDimension(dimSet, def) {
+				size++
+			}
+		}
+		return size <= MaxDimensionSetSize
+	}
+
+	var sets [][]string
+	truncated := false
+	add := func(dimSet []string) {
+		if fits(dimSet) {
+			sets = append(sets, dimSet)
+		} else {
+			truncated = true
+		}
+	}
+
+	switch strategy {
+	case RollupPerDimension:
+		for _, dim := range dimensions {
+			add([]string{dim})
+		}
+		if len(dimensions) > 1 {
+			add(append([]string(nil), dimensions...))
+		}
+	case RollupAll:
+		for size := 1; size <= len(dimensions); size++ {
+			if size > MaxDimensionSetSize {
+				// No larger set can fit
+				return sets, true
+			}
+			if !combinations(dimensions, size, func(dimSet []string) bool {
+				if len(sets) == MaxRollupDimensionSets {
+					return false
+				}
+				add(dimSet)
+				return true
+			}) {
+				return sets, true
+			}
+		}
+	default:
+		add(append([]string(nil), dimensions...))
+	}
+	return sets, truncated
+}
+
+// containsDimension reports whether the dimension set contains dim.
+func containsDimension(dimSet []string, dim string) bool {
+	for _, d := range dimSet {
+		if d == dim {
+			return true
+		}
+	}
+	return false
+}
+
+// combinations calls yield with every combination of size dimensions, in the
+// order of the dimensions, until yield returns false. It reports whether all
+// combinations were yielded.
+func combinations(dimensions []string, size int, yield func([]string) bool) bool {
+	indices := make([]int, size)
+	for i := range indices {
+		indices[i] = i
+	}
+
+	for {
+		dimSet := make([]string, size)
+		for i, index := range indices {
+			dimSet[i] = dimensions[index]
+		}
+		if !yield(dimSet) {
+			return false
+		}
+
+		// Advance to the next combination
+		i := size - 1
+		for i >= 0 && indices[i] == len(dimensions)-size+i {
+			i--
+		}
+		if i < 0 {
+			return true
+		}
+		indices[i]++
+		for j := i + 1; j < size; j++ {
+			indices[j] = indices[j-1] + 1
+		}
+	}
+}
+
+// uniqueDimensions returns the dimensions without repeats, in their original order.
+func uniqueDimensions(dimensions []string) []string {
+	unique := make([]string, 0, len(dimensions))
+	seen := make(map[string]bool, len(dimensions))
+	for _, dim := range dimensions {
+		if !seen[dim] {
+			seen[dim] = true
+			unique = append(unique, dim)
+		}
+	}
+	return unique
+}
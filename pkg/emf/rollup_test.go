@@ -0,0 +1,137 @@
+package emf
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRollupDimensionSets(t *testing.T) {
+	dims := []string{"Service", "Operation", "Region"}
+
+	tests := []struct {
+		strategy RollupStrategy
+		expected [][]string
+	}{
+		{RollupNone, [][]string{{"Service", "Operation", "Region"}}},
+		{RollupPerDimension, [][]string{{"Service"}, {"Operation"}, {"Region"}, {"Service", "Operation", "Region"}}},
+		{RollupAll, [][]string{
+			{"Service"}, {"Operation"}, {"Region"},
+			{"Service", "Operation"}, {"Service", "Region"}, {"Operation", "Region"},
+			{"Service", "Operation", "Region"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if sets := RollupDimensionSets(tt.strategy, dims); !reflect.DeepEqual(sets, tt.expected) {
+			t.Errorf("Expected %v for strategy %d, got %v", tt.expected, tt.strategy, sets)
+		}
+	}
+
+	if sets := RollupDimensionSets(RollupAll, []string{"Service", "Service"}); len(sets) != 1 {
+		t.Errorf("Expected repeated dimensions to be ignored, got %v", sets)
+	}
+	if sets := RollupDimensionSets(RollupAll, nil); sets != nil {
+		t.Errorf("Expected no sets without dimensions, got %v", sets)
+	}
+}
+
+func TestBuilderRollup(t *testing.T) {
+	var warnings []int
+	onWarning := func(w RollupWarning) { warnings = append(warnings, w.Sets) }
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("Service", "API")
+	ml.Builder().
+		OnRollupWarning(onWarning).
+		Dimension("Operation", "GetUser").
+		Dimension("Region", "eu-west-1").
+		Rollup(RollupAll, "Operation", "Region").
+		Metric("Latency", 42.0, UnitMilliseconds)
+
+	if err := ml.Validate(); err != nil {
+		t.Fatalf("Expected a valid log, got: %v", err)
+	}
+	if sets := ml.Metadata().CloudWatchMetrics[0].Dimensions; len(sets) != 3 {
+		t.Errorf("Expected 3 dimension sets, got %v", sets)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warning for a small rollup, got %v", warnings)
+	}
+
+	// Six dimensions generate 63 sets
+	dims := make([]string, 6)
+	for i := range dims {
+		dims[i] = fmt.Sprintf("Dimension%d", i)
+	}
+	NewMetricLog("TestNamespace").Builder().OnRollupWarning(onWarning).Rollup(RollupAll, dims...)
+	if len(warnings) != 1 || warnings[0] != 63 {
+		t.Errorf("Expected a warning for 63 sets, got %v", warnings)
+	}
+}
+
+func TestRollupLimits(t *testing.T) {
+	var truncated bool
+
+	dims := make([]string, MaxDimensionSetSize+1)
+	for i := range dims {
+		dims[i] = fmt.Sprintf("Dimension%d", i)
+	}
+	if sets := RollupDimensionSets(RollupNone, dims); len(sets) != 0 {
+		t.Errorf("Expected sets larger than MaxDimensionSetSize to be skipped, got %d sets", len(sets))
+	}
+	if sets := RollupDimensionSets(RollupPerDimension, dims); len(sets) != len(dims) {
+		t.Errorf("Expected only the single dimension sets, got %d sets", len(sets))
+	}
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("Service", "API")
+	ml.Builder().
+		OnRollupWarning(func(w RollupWarning) { truncated = w.Truncated }).
+		Rollup(RollupAll, dims[:12]...)
+	sets := ml.emf.Aws.CloudWatchMetrics[0].Dimensions
+	if len(sets) != MaxRollupDimensionSets || !truncated {
+		t.Errorf("Expected the rollup to stop at %d sets, got %d (truncated %v)", MaxRollupDimensionSets, len(sets), truncated)
+	}
+
+	// A skipped set is reported
+	truncated = false
+	NewMetricLog("TestNamespace").Builder().
+		OnRollupWarning(func(w RollupWarning) { truncated = w.Truncated }).
+		Rollup(RollupNone, dims...)
+	if !truncated {
+		t.Error("Expected a warning for the skipped set of all dimensions")
+	}
+}
+
+func TestRollupCountsDefaultDimensionsOnce(t *testing.T) {
+	// Service is both a default dimension and part of the rollup
+	dims := make([]string, MaxDimensionSetSize)
+	dims[0] = "Service"
+	for i := 1; i < len(dims); i++ {
+		dims[i] = fmt.Sprintf("Dimension%d", i)
+	}
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("Service", "API")
+	ml.Builder().Rollup(RollupNone, dims...)
+	for _, dim := range dims[1:] {
+		ml.PutDimension(dim, "value")
+	}
+	ml.PutMetric("Latency", 1, UnitMilliseconds)
+	if sets := ml.emf.Aws.CloudWatchMetrics[0].Dimensions; len(sets) != 1 {
+		t.Errorf("Expected the set containing the default dimension to fit, got %v", sets)
+	}
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected a valid log, got: %v", err)
+	}
+
+	// Without Service, the default dimension adds to the set
+	ml = NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("Service", "API")
+	ml.Builder().Rollup(RollupNone, dims[1:]...)
+	ml.Builder().Rollup(RollupNone, append(dims[1:], "Extra")...)
+	if sets := ml.emf.Aws.CloudWatchMetrics[0].Dimensions; len(sets) != 1 {
+		t.Errorf("Expected only the set within the limit, got %d sets", len(sets))
+	}
+}
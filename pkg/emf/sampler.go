@@ -0,0 +1,146 @@
+package emf
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SampleRateProperty is the property that records the rate at which a sampled
+// metric log was kept, so that downstream queries can re-scale the results.
+const SampleRateProperty = "SampleRate"
+
+// SamplerConfig configures a Sampler.
+type SamplerConfig struct {
+	// Rate is the fraction of metric logs to keep, between 0 and 1.
+	// The zero value disables sampling and keeps every log.
+	Rate float64
+	// KeyRates overrides Rate for metric logs whose key, as returned by KeyFunc, is in the map.
+	// A key rate of zero drops every log with that key.
+	KeyRates map[string]float64
+	// KeyFunc returns the sampling key of a metric log. Defaults to the namespace.
+	KeyFunc func(ml *MetricLog) string
+	// IsError reports whether a metric log describes an error. Such logs are always kept.
+	// It may be nil.
+	IsError func(ml *MetricLog) bool
+	// ScaleCounts multiplies the values of metrics with unit Count by the inverse of the
+	// sample rate, so that sums in CloudWatch remain correct without re-scaling.
+	ScaleCounts bool
+	// Rand is the source of randomness. Use a seeded source for deterministic tests.
+	// Defaults to a source seeded with the current time.
+	Rand *rand.Rand
+}
+
+// Sampler decides which metric logs to keep.
+type Sampler struct {
+	config SamplerConfig
+
+	mu sync.Mutex
+}
+
+// NewSampler creates a new Sampler with the given configuration.
+func NewSampler(config SamplerConfig) *Sampler {
+	if config.KeyFunc == nil {
+		config.KeyFunc = func(ml *MetricLog) string {
+			return ml.emf.Aws.CloudWatchMetrics[0].Namespace
+		}
+	}
+	if config.Rand == nil {
+		config.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return &Sampler{
+		config: config,
+	}
+}
+
+// ErrorMetrics returns an IsError function that reports a metric log as an error
+// when any of the named metrics has a value greater than zero.
+func ErrorMetrics(names ...string) func(ml *MetricLog) bool {
+	return func(ml *MetricLog) bool {
+		for _, name := range names {
+			if value, ok := toFloat64(ml.metrics[name]); ok && value > 0 {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Rate returns the sample rate that applies to the given metric log.
+func (s *Sampler) Rate(ml *MetricLog) float64 {
+	if s.config.IsError != nil && s.config.IsError(ml) {
+		return 1
+	}
+
+	rate := s.config.Rate
+	if rate == 0 {
+		rate = 1
+	}
+	if keyRate, exists := s.config.KeyRates[s.config.KeyFunc(ml)]; exists {
+		rate = keyRate
+	}
+	if rate > 1 {
+		return 1
+	}
+	if rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// Sample decides whether to keep the given metric log. Kept logs with a sample rate
+// below 1 are annotated with SampleRateProperty and, if configured, have their counts scaled.
+func (s *Sampler) Sample(ml *MetricLog) bool {
+	rate := s.Rate(ml)
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	keep := s.config.Rand.Float64() < rate
+	s.mu.Unlock()
+
+	if !keep {
+		return false
+	}
+
+	ml.metrics[SampleRateProperty] = rate
+	if s.config.ScaleCounts {
+		for _, metric := range ml.emf.Aws.CloudWatchMetrics[0].Metrics {
+			if metric.Unit == nil || *metric.Unit != UnitCount {
+				continue
+			}
+			if value, ok := toFloat64(ml.metrics[metric.Name]); ok {
+				ml.metrics[metric.Name] = value / rate
+			}
+		}
+	}
+
+	return true
+}
+
+// SamplingSink is a Sink that forwards only the metric logs kept by its Sampler.
+type SamplingSink struct {
+	next    Sink
+	sampler *Sampler
+}
+
+// NewSamplingSink creates a new SamplingSink that forwards sampled logs to next.
+func NewSamplingSink(next Sink, config SamplerConfig) *SamplingSink {
+	return &SamplingSink{
+		next:    next,
+		sampler: NewSampler(config),
+	}
+}
+
+// Emit forwards the metric log to the next sink if the sampler keeps it.
+func (s *SamplingSink) Emit(ml *MetricLog) error {
+	if !s.sampler.Sample(ml) {
+		return nil
+	}
+	return s.next.Emit(ml)
+}
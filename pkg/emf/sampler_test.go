@@ -0,0 +1,119 @@
+package emf
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSamplerFixedRate(t *testing.T) {
+	sink, logs := collectingSink()
+	sampling := NewSamplingSink(sink, SamplerConfig{
+		Rate: 0.25,
+		Rand: rand.New(rand.NewSource(42)),
+	})
+
+	const total = 4000
+	for i := 0; i < total; i++ {
+		if err := sampling.Emit(newRequestLog("API", 10)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	kept := logs()
+	if len(kept) < total/4-200 || len(kept) > total/4+200 {
+		t.Errorf("Expected roughly %d kept logs, got %d", total/4, len(kept))
+	}
+
+	for _, ml := range kept {
+		if ml.metrics[SampleRateProperty] != 0.25 {
+			t.Fatalf("Expected %s to be 0.25, got %v", SampleRateProperty, ml.metrics[SampleRateProperty])
+		}
+	}
+}
+
+func TestSamplerIsDeterministic(t *testing.T) {
+	run := func() []bool {
+		sampler := NewSampler(SamplerConfig{Rate: 0.5, Rand: rand.New(rand.NewSource(7))})
+		var decisions []bool
+		for i := 0; i < 50; i++ {
+			decisions = append(decisions, sampler.Sample(newRequestLog("API", 10)))
+		}
+		return decisions
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected identical decisions with the same seed, differed at %d", i)
+		}
+	}
+}
+
+func TestSamplerKeyRates(t *testing.T) {
+	sampler := NewSampler(SamplerConfig{
+		Rate: 0.5,
+		KeyRates: map[string]float64{
+			"API":    0,
+			"Worker": 1,
+		},
+		KeyFunc: func(ml *MetricLog) string {
+			return ml.metrics["Service"].(string)
+		},
+		Rand: rand.New(rand.NewSource(1)),
+	})
+
+	for i := 0; i < 100; i++ {
+		if sampler.Sample(newRequestLog("API", 10)) {
+			t.Fatal("Expected logs with a key rate of 0 to be dropped")
+		}
+		ml := newRequestLog("Worker", 10)
+		if !sampler.Sample(ml) {
+			t.Fatal("Expected logs with a key rate of 1 to be kept")
+		}
+		if _, exists := ml.metrics[SampleRateProperty]; exists {
+			t.Fatal("Expected unsampled logs not to carry a sample rate")
+		}
+	}
+
+	if rate := sampler.Rate(newRequestLog("Other", 10)); rate != 0.5 {
+		t.Errorf("Expected the default rate for unknown keys, got %v", rate)
+	}
+}
+
+func TestSamplerKeepsErrors(t *testing.T) {
+	sampler := NewSampler(SamplerConfig{
+		Rate:    0.01,
+		IsError: ErrorMetrics("Errors"),
+		Rand:    rand.New(rand.NewSource(1)),
+	})
+
+	for i := 0; i < 100; i++ {
+		ml := newRequestLog("API", 10)
+		ml.PutMetric("Errors", 1, UnitCount)
+		if !sampler.Sample(ml) {
+			t.Fatal("Expected error logs to always be kept")
+		}
+	}
+}
+
+func TestSamplerScaleCounts(t *testing.T) {
+	sampler := NewSampler(SamplerConfig{
+		Rate:        0.5,
+		ScaleCounts: true,
+		Rand:        rand.New(rand.NewSource(3)),
+	})
+
+	for {
+		ml := newRequestLog("API", 10)
+		if !sampler.Sample(ml) {
+			continue
+		}
+		if ml.metrics["Requests"] != 2.0 {
+			t.Errorf("Expected Requests to be scaled to 2, got %v", ml.metrics["Requests"])
+		}
+		if ml.metrics["Latency"] != 10.0 {
+			t.Errorf("Expected Latency to be left unchanged, got %v", ml.metrics["Latency"])
+		}
+		break
+	}
+}
@@ -0,0 +1,272 @@
+package emf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Sanitizer defaults
+const (
+	// DefaultPlaceholder replaces empty names and dimension values.
+	DefaultPlaceholder = "Unknown"
+	// DefaultReplacement replaces invalid characters.
+	DefaultReplacement = "_"
+)
+
+// ChangeKind is the kind of name or value changed by a Sanitizer.
+type ChangeKind string
+
+// Change kinds
+const (
+	ChangeNamespace      ChangeKind = "namespace"
+	ChangeMetricName     ChangeKind = "metricName"
+	ChangeDimensionName  ChangeKind = "dimensionName"
+	ChangeDimensionValue ChangeKind = "dimensionValue"
+)
+
+// Change describes a name or value changed by a Sanitizer.
+type Change struct {
+	Kind ChangeKind
+	// Dimension is the (sanitized) dimension name of a changed dimension value.
+	Dimension string
+	Original  string
+	Sanitized string
+}
+
+// String returns a description of the change.
+func (c Change) String() string {
+	if c.Kind == ChangeDimensionValue {
+		return fmt.Sprintf("sanitized value of dimension '%s' from %q to %q", c.Dimension, c.Original, c.Sanitized)
+	}
+	return fmt.Sprintf("sanitized %s %q to %q", c.Kind, c.Original, c.Sanitized)
+}
+
+// SanitizerConfig configures a Sanitizer.
+type SanitizerConfig struct {
+	// Placeholder replaces names and dimension values that are empty after trimming.
+	// Defaults to DefaultPlaceholder.
+	Placeholder string
+	// Replacement replaces invalid characters. Defaults to DefaultReplacement.
+	Replacement string
+	// OnChange is called for every name or value the sanitizer changes. It may be nil.
+	OnChange func(Change)
+}
+
+// Sanitizer rewrites the names and dimension values of metric logs so that
+// CloudWatch accepts them. It trims surrounding white space, replaces characters
+// CloudWatch rejects, truncates to the limits in the validation constants and
+// substitutes a placeholder for empty values.
+//
+// Namespaces may contain ASCII letters, digits and the characters . - _ / # : and
+// space. Metric names, dimension names and dimension values may contain printable
+// ASCII characters, and dimension names must not start with a colon.
+type Sanitizer struct {
+	config SanitizerConfig
+}
+
+// NewSanitizer creates a new Sanitizer with the given configuration.
+func NewSanitizer(config SanitizerConfig) *Sanitizer {
+	if config.Placeholder == "" {
+		config.Placeholder = DefaultPlaceholder
+	}
+	if config.Replacement == "" {
+		config.Replacement = DefaultReplacement
+	}
+
+	return &Sanitizer{
+		config: config,
+	}
+}
+
+// Sanitize rewrites the namespace, metric names, dimension names and dimension
+// values of the metric log in place and returns it. Renamed metrics and dimensions
+// keep their values; if the new name is already in use, a numeric suffix is added.
+// Dimension values that are not strings are converted to strings and reported as
+// changes. Properties are left unchanged.
+func (s *Sanitizer) Sanitize(ml *MetricLog) *MetricLog {
+	renamed := make(map[string]string)
+	for i := range ml.emf.Aws.CloudWatchMetrics {
+		directive := &ml.emf.Aws.CloudWatchMetrics[i]
+		directive.Namespace = s.sanitize(ChangeNamespace, "", directive.Namespace, MaxNamespaceLength, isNamespaceChar)
+
+		for j := range directive.Metrics {
+			metric := &directive.Metrics[j]
+			metric.Name = s.rename(ml, renamed, ChangeMetricName, metric.Name, MaxMetricNameLength)
+		}
+
+		for _, dimSet := range directive.Dimensions {
+			for k, dim := range dimSet {
+				dimSet[k] = s.rename(ml, renamed, ChangeDimensionName, dim, MaxDimensionNameLength)
+			}
+		}
+	}
+	for i, dim := range ml.defaultDimensions {
+		ml.defaultDimensions[i] = s.rename(ml, renamed, ChangeDimensionName, dim, MaxDimensionNameLength)
+	}
+
+	// Sanitize the values of all referenced dimensions
+	seen := make(map[string]bool)
+	for _, dim := range ml.referencedDimensions() {
+		if seen[dim] {
+			continue
+		}
+		seen[dim] = true
+
+		value, exists := ml.metrics[dim]
+		if !exists {
+			continue
+		}
+		if str, isString := value.(string); isString {
+			ml.metrics[dim] = s.sanitize(ChangeDimensionValue, dim, str, MaxDimensionValueLength, isValueChar)
+			continue
+		}
+
+		// Values of other types are converted and reported with their type
+		sanitized := s.clean(fmt.Sprint(value), MaxDimensionValueLength, isValueChar)
+		s.report(Change{Kind: ChangeDimensionValue, Dimension: dim, Original: fmt.Sprintf("%T(%v)", value, value), Sanitized: sanitized})
+		ml.metrics[dim] = sanitized
+	}
+
+	return ml
+}
+
+// referencedDimensions returns the dimensions referenced by the dimension sets and
+// the default dimensions of the log, possibly more than once.
+func (ml *MetricLog) referencedDimensions() []string {
+	dimensions := append([]string(nil), ml.defaultDimensions...)
+	for _, directive := range ml.emf.Aws.CloudWatchMetrics {
+		for _, dimSet := range directive.Dimensions {
+			dimensions = append(dimensions, dimSet...)
+		}
+	}
+	return dimensions
+}
+
+// rename sanitizes a metric or dimension name and moves its value to the new key,
+// unless the key is in use. renamed caches the names sanitized so far, so that
+// each name is changed and reported once.
+func (s *Sanitizer) rename(ml *MetricLog, renamed map[string]string, kind ChangeKind, name string, maxLength int) string {
+	key := string(kind) + "\x00" + name
+	if sanitized, exists := renamed[key]; exists {
+		return sanitized
+	}
+
+	isValid := isValueChar
+	if kind == ChangeDimensionName {
+		isValid = isDimensionNameChar
+	}
+	sanitized := s.sanitize(kind, "", name, maxLength, isValid)
+
+	if sanitized != name {
+		if value, exists := ml.metrics[name]; exists {
+			delete(ml.metrics, name)
+			if _, taken := ml.metrics[sanitized]; taken {
+				unique := s.uniqueName(ml, sanitized, maxLength)
+				s.report(Change{Kind: kind, Original: sanitized, Sanitized: unique})
+				sanitized = unique
+			}
+			ml.metrics[sanitized] = value
+		}
+	}
+	renamed[key] = sanitized
+	return sanitized
+}
+
+// uniqueName returns name with the lowest numeric suffix, separated by the
+// replacement, that is not in use in the log and keeps it within maxLength bytes.
+func (s *Sanitizer) uniqueName(ml *MetricLog, name string, maxLength int) string {
+	for i := 2; ; i++ {
+		suffix := s.config.Replacement + strconv.Itoa(i)
+		candidate := truncate(name, maxLength-len(suffix)) + suffix
+		if _, taken := ml.metrics[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+// sanitize cleans a name or value and reports the change if it was modified.
+func (s *Sanitizer) sanitize(kind ChangeKind, dimension, original string, maxLength int, isValid func(i int, r rune) bool) string {
+	sanitized := s.clean(original, maxLength, isValid)
+	if sanitized != original {
+		s.report(Change{Kind: kind, Dimension: dimension, Original: original, Sanitized: sanitized})
+	}
+	return sanitized
+}
+
+// clean trims a name or value, replaces invalid characters, truncates it to
+// maxLength bytes and substitutes the placeholder if it is empty.
+func (s *Sanitizer) clean(original string, maxLength int, isValid func(i int, r rune) bool) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(original) {
+		if isValid(i, r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(s.config.Replacement)
+		}
+	}
+
+	sanitized := truncate(b.String(), maxLength)
+	if sanitized == "" {
+		sanitized = s.config.Placeholder
+	}
+	return sanitized
+}
+
+// truncate truncates s to at most maxLength bytes without splitting a UTF-8 sequence.
+func truncate(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	for maxLength > 0 && !utf8.RuneStart(s[maxLength]) {
+		maxLength--
+	}
+	return s[:maxLength]
+}
+
+// report passes a change to the OnChange callback.
+func (s *Sanitizer) report(change Change) {
+	if s.config.OnChange != nil {
+		s.config.OnChange(change)
+	}
+}
+
+// isValueChar reports whether r is valid in a metric name or dimension value.
+func isValueChar(_ int, r rune) bool {
+	return isPrintableASCII(r)
+}
+
+// isDimensionNameChar reports whether r is valid at byte offset i of a dimension name.
+func isDimensionNameChar(i int, r rune) bool {
+	return isPrintableASCII(r) && !(i == 0 && r == ':')
+}
+
+// isNamespaceChar reports whether r is valid in a namespace.
+func isNamespaceChar(_ int, r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	default:
+		return strings.ContainsRune(".-_/#: ", r)
+	}
+}
+
+// SanitizingSink is a Sink that sanitizes metric logs before forwarding them.
+type SanitizingSink struct {
+	next      Sink
+	sanitizer *Sanitizer
+}
+
+// NewSanitizingSink creates a new SanitizingSink that forwards sanitized logs to next.
+func NewSanitizingSink(next Sink, config SanitizerConfig) *SanitizingSink {
+	return &SanitizingSink{
+		next:      next,
+		sanitizer: NewSanitizer(config),
+	}
+}
+
+// Emit sanitizes the metric log in place and forwards it to the next sink.
+func (s *SanitizingSink) Emit(ml *MetricLog) error {
+	return s.next.Emit(s.sanitizer.Sanitize(ml))
+}
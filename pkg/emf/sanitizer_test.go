@@ -0,0 +1,162 @@
+package emf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitize(t *testing.T) {
+	var changes []Change
+	sanitizer := NewSanitizer(SanitizerConfig{OnChange: func(c Change) {
+		changes = append(changes, c)
+	}})
+
+	ml := NewMetricLog(" My App!")
+	ml.PutDefaultDimension(":Service", "API")
+	ml.PutDimension("Région", "  ")
+	ml.PutDimension("Host", "host\n1")
+	ml.metrics["Shard"] = 7
+	ml.WithDimensionSet([]string{"Région", "Host"})
+	ml.WithDimensionSet([]string{"Shard"})
+	ml.PutMetric("Latency ", 42.0, UnitMilliseconds)
+	ml.PutMetric("Count", 1, UnitCount)
+	ml.Builder().Property("Path", "  /über  ")
+
+	sanitizer.Sanitize(ml)
+
+	directive := ml.emf.Aws.CloudWatchMetrics[0]
+	if directive.Namespace != "My App_" {
+		t.Errorf("Expected the namespace to be sanitized, got %q", directive.Namespace)
+	}
+	if directive.Metrics[0].Name != "Latency" || directive.Metrics[1].Name != "Count" {
+		t.Errorf("Expected the metric names to be sanitized, got %+v", directive.Metrics)
+	}
+	if strings.Join(directive.Dimensions[0], ",") != "R_gion,Host" {
+		t.Errorf("Expected the dimension names to be sanitized, got %v", directive.Dimensions)
+	}
+	if ml.defaultDimensions[0] != "_Service" {
+		t.Errorf("Expected the leading colon to be replaced, got %v", ml.defaultDimensions)
+	}
+
+	for key, expected := range map[string]interface{}{
+		"_Service": "API",
+		"R_gion":   DefaultPlaceholder,
+		"Host":     "host_1",
+		"Shard":    "7",
+		"Latency":  42.0,
+		"Path":     "  /über  ",
+	} {
+		if value, ok := ml.Value(key); !ok || value != expected {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, value)
+		}
+	}
+	for _, key := range []string{":Service", "Région", "Latency "} {
+		if _, ok := ml.Value(key); ok {
+			t.Errorf("Expected %q to be renamed", key)
+		}
+	}
+
+	if len(changes) != 7 {
+		t.Errorf("Expected 7 changes, got %d: %v", len(changes), changes)
+	}
+	expected := Change{Kind: ChangeDimensionValue, Dimension: "Shard", Original: "int(7)", Sanitized: "7"}
+	if !containsChange(changes, expected) {
+		t.Errorf("Expected the conversion of Shard to be reported, got %v", changes)
+	}
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected a valid log, got: %v", err)
+	}
+}
+
+func TestSanitizeTruncates(t *testing.T) {
+	sanitizer := NewSanitizer(SanitizerConfig{Placeholder: "none", Replacement: "-"})
+
+	ml := NewMetricLog(strings.Repeat("n", MaxNamespaceLength+1))
+	ml.PutDimension(strings.Repeat("d", MaxDimensionNameLength+1), strings.Repeat("v", MaxDimensionValueLength+1))
+	ml.PutDimension("Empty", "")
+	ml.WithDimensionSet([]string{strings.Repeat("d", MaxDimensionNameLength+1), "Empty"})
+	ml.PutMetric("Latency", 1, UnitMilliseconds)
+
+	sanitizer.Sanitize(ml)
+
+	if len(ml.emf.Aws.CloudWatchMetrics[0].Namespace) != MaxNamespaceLength {
+		t.Error("Expected the namespace to be truncated")
+	}
+	name := strings.Repeat("d", MaxDimensionNameLength)
+	if value, _ := ml.Value(name); len(value.(string)) != MaxDimensionValueLength {
+		t.Errorf("Expected the dimension name and value to be truncated, got %v", ml.emf.Aws.CloudWatchMetrics[0].Dimensions)
+	}
+	if value, _ := ml.Value("Empty"); value != "none" {
+		t.Errorf("Expected the configured placeholder, got %v", value)
+	}
+}
+
+func TestSanitizeTruncatesOnCharacterBoundary(t *testing.T) {
+	sanitizer := NewSanitizer(SanitizerConfig{Replacement: "·"})
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "a"+strings.Repeat("\x01", MaxDimensionValueLength))
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Latency", 1, UnitMilliseconds)
+
+	sanitizer.Sanitize(ml)
+
+	value, _ := ml.Value("Service")
+	if !utf8.ValidString(value.(string)) || len(value.(string)) > MaxDimensionValueLength {
+		t.Errorf("Expected valid UTF-8 within %d bytes, got %d bytes", MaxDimensionValueLength, len(value.(string)))
+	}
+}
+
+func TestSanitizeKeepsCollidingValues(t *testing.T) {
+	var changes []Change
+	sanitizer := NewSanitizer(SanitizerConfig{OnChange: func(c Change) { changes = append(changes, c) }})
+
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDimension("Service", "API")
+	ml.PutDimension("Service\n", "Worker")
+	ml.WithDimensionSet([]string{"Service", "Service\n"})
+	ml.PutMetric("Latency", 1, UnitMilliseconds)
+
+	sanitizer.Sanitize(ml)
+
+	if value, _ := ml.Value("Service"); value != "API" {
+		t.Errorf("Expected the existing value to be kept, got %v", value)
+	}
+	if value, _ := ml.Value("Service_2"); value != "Worker" {
+		t.Errorf("Expected the colliding value under Service_2, got %v", value)
+	}
+	if dims := ml.emf.Aws.CloudWatchMetrics[0].Dimensions[0]; strings.Join(dims, ",") != "Service,Service_2" {
+		t.Errorf("Expected the dimension set to use the new name, got %v", dims)
+	}
+	if len(changes) == 0 || changes[len(changes)-1].Sanitized != "Service_2" {
+		t.Errorf("Expected the collision to be reported, got %v", changes)
+	}
+}
+
+func TestSanitizingSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSanitizingSink(NewWriterSink(&buf), SanitizerConfig{})
+
+	ml := NewMetricLog("App")
+	ml.PutDimension("Service", "")
+	ml.WithDimensionSet([]string{"Service"})
+	ml.PutMetric("Latency", 1, UnitMilliseconds)
+	if err := sink.Emit(ml); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Service":"Unknown"`) {
+		t.Errorf("Expected the sanitized log to be written, got %s", buf.String())
+	}
+}
+
+// containsChange reports whether changes contains change.
+func containsChange(changes []Change, change Change) bool {
+	for _, c := range changes {
+		if c == change {
+			return true
+		}
+	}
+	return false
+}
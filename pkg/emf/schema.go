@@ -0,0 +1,246 @@
+package emf
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// schemaJSON is the EMF JSON schema.
+//
+//go:embed emf-format.json
+var schemaJSON []byte
+
+// Schema returns a copy of the EMF JSON schema that ValidateJSON checks documents against.
+func Schema() []byte {
+	return append([]byte(nil), schemaJSON...)
+}
+
+// SchemaViolation is a part of a document that does not conform to the EMF schema.
+type SchemaViolation struct {
+	// Field is the dotted path of the offending value, e.g. "_aws.CloudWatchMetrics.0.Namespace",
+	// or "(root)" for the document itself.
+	Field   string
+	Message string
+}
+
+// String returns the violation as "field: message".
+func (v SchemaViolation) String() string {
+	return v.Field + ": " + v.Message
+}
+
+// SchemaError is returned by ValidateJSON for documents that do not conform to the EMF schema.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+// Error implements the error interface.
+func (e *SchemaError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.String()
+	}
+	return "document does not conform to the EMF schema: " + strings.Join(messages, "; ")
+}
+
+// schemaNode is the subset of JSON schema keywords used by the EMF schema.
+// Schemas with other keywords are rejected, so that the schema cannot use a
+// keyword that would silently not be validated.
+type schemaNode struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*schemaNode `json:"properties"`
+	Items      *schemaNode            `json:"items"`
+	MinItems   *int                   `json:"minItems"`
+	MaxItems   *int                   `json:"maxItems"`
+	MinLength  *int                   `json:"minLength"`
+	MaxLength  *int                   `json:"maxLength"`
+	Pattern    string                 `json:"pattern"`
+
+	// Annotations, which do not affect validation
+	ID          string          `json:"$id"`
+	SchemaURI   string          `json:"$schema"`
+	Comment     string          `json:"$comment"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Examples    json.RawMessage `json:"examples"`
+	Default     json.RawMessage `json:"default"`
+
+	pattern *regexp.Regexp
+}
+
+var (
+	compileOnce    sync.Once
+	compiledSchema *schemaNode
+	compileErr     error
+)
+
+// ValidateJSON checks an EMF document against the embedded EMF JSON schema. It
+// returns a *SchemaError listing every violation, or an error if the document is
+// not valid JSON. Unlike ParseMetricLog and Validate, it only checks the structure
+// the schema describes, so it is suitable for rejecting malformed events received
+// from other services before they are processed further.
+func ValidateJSON(data []byte) error {
+	compileOnce.Do(func() {
+		compiledSchema, compileErr = compileSchema(schemaJSON)
+	})
+	if compileErr != nil {
+		return fmt.Errorf("failed to load EMF schema: %w", compileErr)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid JSON: unexpected data after the document")
+	}
+
+	var violations []SchemaViolation
+	compiledSchema.validate("(root)", document, &violations)
+	if len(violations) > 0 {
+		return &SchemaError{Violations: violations}
+	}
+	return nil
+}
+
+// compileSchema parses a schema and compiles its patterns. It returns an error for
+// keywords that schemaNode does not implement.
+func compileSchema(data []byte) (*schemaNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var root schemaNode
+	if err := decoder.Decode(&root); err != nil {
+		if strings.HasPrefix(err.Error(), "json: unknown field") {
+			return nil, fmt.Errorf("unsupported schema keyword: %w", err)
+		}
+		return nil, err
+	}
+	if err := root.compile(); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// compile compiles the patterns of the node and its children.
+func (n *schemaNode) compile() error {
+	if n.Pattern != "" {
+		pattern, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", n.Pattern, err)
+		}
+		n.pattern = pattern
+	}
+	for _, property := range n.Properties {
+		if err := property.compile(); err != nil {
+			return err
+		}
+	}
+	if n.Items != nil {
+		return n.Items.compile()
+	}
+	return nil
+}
+
+// validate appends the violations of the value at the given path to violations.
+func (n *schemaNode) validate(path string, value interface{}, violations *[]SchemaViolation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if n.Type != "" && !hasSchemaType(value, n.Type) {
+		report("invalid type, expected %s, got %s", n.Type, schemaTypeOf(value))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range n.Required {
+			if _, exists := v[name]; !exists {
+				report("%s is required", name)
+			}
+		}
+		names := make([]string, 0, len(n.Properties))
+		for name := range n.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, exists := v[name]; exists {
+				n.Properties[name].validate(childPath(path, name), property, violations)
+			}
+		}
+	case []interface{}:
+		if n.MinItems != nil && len(v) < *n.MinItems {
+			report("array must have at least %d items", *n.MinItems)
+		}
+		if n.MaxItems != nil && len(v) > *n.MaxItems {
+			report("array must have at most %d items", *n.MaxItems)
+		}
+		if n.Items != nil {
+			for i, item := range v {
+				n.Items.validate(childPath(path, strconv.Itoa(i)), item, violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n.MinLength != nil && length < *n.MinLength {
+			report("string length must be at least %d", *n.MinLength)
+		}
+		if n.MaxLength != nil && length > *n.MaxLength {
+			report("string length must be at most %d", *n.MaxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			report("does not match pattern '%s'", n.Pattern)
+		}
+	}
+}
+
+// childPath returns the path of a property or item below path.
+func childPath(path, name string) string {
+	if path == "(root)" {
+		return name
+	}
+	return path + "." + name
+}
+
+// hasSchemaType reports whether a decoded JSON value is of the given schema type.
+func hasSchemaType(value interface{}, schemaType string) bool {
+	if schemaType == "integer" {
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := number.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return schemaTypeOf(value) == schemaType
+}
+
+// schemaTypeOf returns the schema type of a decoded JSON value.
+func schemaTypeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
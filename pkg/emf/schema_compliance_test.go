@@ -3,27 +3,11 @@ package emf
 import (
 	"bytes"
 	"encoding/json"
-	"os"
 	"testing"
-
-	"github.com/xeipuuv/gojsonschema"
 )
 
 // TestComplianceWithEmfFormat verifies that all generated EMF logs comply with the official EMF schema
 func TestComplianceWithEmfFormat(t *testing.T) {
-	// Load the schema directly from the file in the repository
-	schemaBytes, err := os.ReadFile("emf-format.json")
-	if err != nil {
-		t.Fatalf("Failed to read the EMF JSON schema: %v", err)
-	}
-
-	// Create a schema loader
-	schemaLoader := gojsonschema.NewStringLoader(string(schemaBytes))
-	schema, err := gojsonschema.NewSchema(schemaLoader)
-	if err != nil {
-		t.Fatalf("Failed to parse the EMF JSON schema: %v", err)
-	}
-
 	// Define test cases that generate various EMF logs
 	testCases := []struct {
 		name        string
@@ -131,25 +115,9 @@ func TestComplianceWithEmfFormat(t *testing.T) {
 				}
 			}
 
-			// Create a document loader for the generated JSON
-			documentLoader := gojsonschema.NewStringLoader(string(jsonBytes))
-
 			// Validate against the schema
-			result, err := schema.Validate(documentLoader)
-			if err != nil {
-				t.Fatalf("Schema validation error: %v", err)
-			}
-
-			// Check if validation passed
-			if !result.Valid() {
-				var errDetails string
-				for i, desc := range result.Errors() {
-					if i > 0 {
-						errDetails += ", "
-					}
-					errDetails += desc.String()
-				}
-				t.Errorf("EMF log doesn't comply with schema: %s", errDetails)
+			if err := ValidateJSON(jsonBytes); err != nil {
+				t.Errorf("EMF log doesn't comply with schema: %v", err)
 			}
 		})
 	}
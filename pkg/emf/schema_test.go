@@ -3,63 +3,20 @@ package emf
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"testing"
-
-	"github.com/xeipuuv/gojsonschema"
 )
 
-// loadJSONSchema loads the EMF JSON schema from the given file path
-func loadJSONSchema(t *testing.T) *gojsonschema.Schema {
-	t.Helper()
-
-	schemaPath := filepath.Join(".", "emf-format.json")
-	schemaBytes, err := os.ReadFile(schemaPath)
-	if err != nil {
-		t.Fatalf("Failed to read JSON schema file: %v", err)
-	}
-
-	// Load the JSON schema
-	schemaLoader := gojsonschema.NewStringLoader(string(schemaBytes))
-	schema, err := gojsonschema.NewSchema(schemaLoader)
-	if err != nil {
-		t.Fatalf("Failed to load JSON schema: %v", err)
-	}
-
-	return schema
-}
-
 // validateAgainstSchema validates the given JSON against the EMF JSON schema
-func validateAgainstSchema(t *testing.T, jsonBytes []byte, schema *gojsonschema.Schema) {
+func validateAgainstSchema(t *testing.T, jsonBytes []byte) {
 	t.Helper()
 
-	// Load the JSON data
-	documentLoader := gojsonschema.NewStringLoader(string(jsonBytes))
-
-	// Validate against the schema
-	result, err := schema.Validate(documentLoader)
-	if err != nil {
-		t.Fatalf("Error validating JSON: %v", err)
-	}
-
-	// Check validation result
-	if !result.Valid() {
-		var errMsg string
-		for i, desc := range result.Errors() {
-			if i > 0 {
-				errMsg += ", "
-			}
-			errMsg += desc.String()
-		}
-		t.Fatalf("Schema validation failed: %s", errMsg)
+	if err := ValidateJSON(jsonBytes); err != nil {
+		t.Fatalf("Schema validation failed: %v", err)
 	}
 }
 
 // TestJsonSchemaValidation tests that the generated JSON conforms to the EMF schema
 func TestJsonSchemaValidation(t *testing.T) {
-	schema := loadJSONSchema(t)
-
 	tests := []struct {
 		name     string
 		setup    func() *MetricLog
@@ -233,7 +190,7 @@ func TestJsonSchemaValidation(t *testing.T) {
 			}
 
 			// Validate against schema
-			validateAgainstSchema(t, jsonBytes, schema)
+			validateAgainstSchema(t, jsonBytes)
 
 			// Also validate the data structure
 			var data map[string]interface{}
@@ -249,8 +206,6 @@ func TestJsonSchemaValidation(t *testing.T) {
 
 // TestSchemaValidationWithComplexData tests complex scenarios and edge cases
 func TestSchemaValidationWithComplexData(t *testing.T) {
-	schema := loadJSONSchema(t)
-
 	tests := []struct {
 		name     string
 		setup    func() *MetricLog
@@ -398,7 +353,7 @@ func TestSchemaValidationWithComplexData(t *testing.T) {
 			}
 
 			// Validate against schema
-			validateAgainstSchema(t, jsonBytes, schema)
+			validateAgainstSchema(t, jsonBytes)
 
 			// Also validate the data structure
 			var data map[string]interface{}
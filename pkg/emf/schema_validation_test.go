@@ -2,6 +2,8 @@ package emf
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -274,3 +276,156 @@ func contains(s, substr string) bool {
 func stringPtr(s string) *string {
 	return &s
 }
+
+// TestValidateJSON tests that arbitrary documents are checked against the embedded schema
+func TestValidateJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		document   string
+		violations []string
+	}{
+		{
+			name:     "valid document",
+			document: `{"_aws":{"Timestamp":1600000000000,"CloudWatchMetrics":[{"Namespace":"App","Dimensions":[["Service"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds","StorageResolution":1}]}]},"Service":"API","Latency":1}`,
+		},
+		{
+			name:       "missing metadata",
+			document:   `{"Latency":1}`,
+			violations: []string{"(root): _aws is required"},
+		},
+		{
+			name:     "invalid metadata",
+			document: `{"_aws":{"Timestamp":1.5,"CloudWatchMetrics":[{"Namespace":"","Dimensions":[],"Metrics":[{"Unit":"Hours"}]}]}}`,
+			violations: []string{
+				"_aws.CloudWatchMetrics.0.Dimensions: array must have at least 1 items",
+				"_aws.CloudWatchMetrics.0.Metrics.0: Name is required",
+				"_aws.CloudWatchMetrics.0.Metrics.0.Unit: does not match pattern",
+				"_aws.CloudWatchMetrics.0.Namespace: string length must be at least 1",
+				"_aws.Timestamp: invalid type, expected integer, got number",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJSON([]byte(tt.document))
+			if len(tt.violations) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+
+			schemaErr, ok := err.(*SchemaError)
+			if !ok {
+				t.Fatalf("Expected a *SchemaError, got %v", err)
+			}
+			if len(schemaErr.Violations) != len(tt.violations) {
+				t.Fatalf("Expected %d violations, got %v", len(tt.violations), schemaErr.Violations)
+			}
+			for i, expected := range tt.violations {
+				if got := schemaErr.Violations[i].String(); !strings.HasPrefix(got, expected) {
+					t.Errorf("Expected violation %q, got %q", expected, got)
+				}
+			}
+		})
+	}
+
+	if err := ValidateJSON([]byte(`{"_aws":`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+	if !json.Valid(Schema()) {
+		t.Error("Expected Schema to return the JSON schema")
+	}
+}
+
+// TestDimensionValidation tests the rules for dimension names and values
+func TestCompileSchema(t *testing.T) {
+	if _, err := compileSchema(Schema()); err != nil {
+		t.Fatalf("Expected the embedded schema to compile, got: %v", err)
+	}
+
+	tests := map[string]string{
+		"unsupported keyword":        `{"type": "object", "additionalProperties": false}`,
+		"nested unsupported keyword": `{"type": "object", "properties": {"Unit": {"type": "string", "enum": ["Count"]}}}`,
+		"invalid pattern":            `{"type": "string", "pattern": "("}`,
+	}
+	for name, schema := range tests {
+		if _, err := compileSchema([]byte(schema)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestDimensionValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		key           string
+		value         interface{}
+		dimSet        []string
+		errorContains string
+	}{
+		{name: "valid dimension", key: "Service", value: "API"},
+		{name: "non-string value", key: "Shard", value: 7, errorContains: "must have a string value, got int"},
+		{name: "empty value", key: "Service", value: "", errorContains: "non-empty value"},
+		{name: "blank value", key: "Service", value: "  ", errorContains: "non-empty value"},
+		{name: "value too long", key: "Service", value: strings.Repeat("a", MaxDimensionValueLength+1), errorContains: "exceeds maximum length of 1024"},
+		{name: "value at maximum length", key: "Service", value: strings.Repeat("a", MaxDimensionValueLength)},
+		{name: "non-ASCII value", key: "Service", value: "Zürich", errorContains: "printable ASCII"},
+		{name: "control character in value", key: "Service", value: "a\tb", errorContains: "printable ASCII"},
+		{name: "non-ASCII name", key: "Région", value: "eu", errorContains: "printable ASCII"},
+		{name: "colon prefix", key: ":Service", value: "API", errorContains: "must not start with a colon"},
+		{name: "empty name", key: "", value: "API", errorContains: "must not be empty"},
+		{name: "duplicate dimension", key: "Service", value: "API", dimSet: []string{"Service", "Service"}, errorContains: "more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ml := NewMetricLog("TestNamespace")
+			ml.metrics[tt.key] = tt.value
+			dimSet := tt.dimSet
+			if dimSet == nil {
+				dimSet = []string{tt.key}
+			}
+			ml.WithDimensionSet(dimSet)
+			ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+			err := ml.Validate()
+			if tt.errorContains == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorContains, err)
+			}
+		})
+	}
+}
+
+// TestDimensionSetSizeWithDefaults tests that default dimensions count towards the dimension set limit
+func TestDimensionSetSizeWithDefaults(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	ml.PutDefaultDimension("Service", "API")
+	ml.PutDefaultDimension("Stage", "prod")
+
+	dimSet := make([]string, MaxDimensionSetSize-1)
+	for i := range dimSet {
+		dimSet[i] = fmt.Sprintf("Dimension%d", i)
+		ml.PutDimension(dimSet[i], "value")
+	}
+	ml.WithDimensionSet(dimSet)
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+
+	err := ml.Validate()
+	if err == nil || !strings.Contains(err.Error(), "31 dimensions including 2 default dimensions") {
+		t.Errorf("Expected the default dimensions to count towards the limit, got %v", err)
+	}
+
+	// Opting out of the default dimensions makes the set valid
+	ml.SetDimensions(dimSet)
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected no error without the default dimensions, got: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package emf
+
+import (
+	"io"
+	"sync"
+)
+
+// Sink receives metric logs that are ready to be published.
+// Implementations must be safe for concurrent use.
+type Sink interface {
+	Emit(ml *MetricLog) error
+}
+
+// SinkFunc adapts an ordinary function to the Sink interface.
+type SinkFunc func(ml *MetricLog) error
+
+// Emit calls f(ml).
+func (f SinkFunc) Emit(ml *MetricLog) error {
+	return f(ml)
+}
+
+// WriterSink writes each metric log as a single line of JSON to an io.Writer,
+// which is the form CloudWatch Logs and the CloudWatch agent expect.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a new WriterSink that writes to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{
+		w: w,
+	}
+}
+
+// Emit marshals the metric log and writes it to the underlying writer followed by a newline.
+func (s *WriterSink) Emit(ml *MetricLog) error {
+	bytes, err := ml.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	bytes = append(bytes, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(bytes)
+	return err
+}
@@ -0,0 +1,43 @@
+package emf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	for _, service := range []string{"API", "Worker"} {
+		if err := sink.Emit(newRequestLog(service, 42)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	for _, line := range lines {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+			t.Errorf("Expected each line to be valid JSON, got error: %v", err)
+		}
+	}
+}
+
+func TestWriterSinkInvalidLog(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	if err := sink.Emit(NewMetricLog("TestNamespace")); err == nil {
+		t.Error("Expected an error for an invalid metric log")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing to be written, got %q", buf.String())
+	}
+}
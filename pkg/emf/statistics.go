@@ -0,0 +1,220 @@
+package emf
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// MaxValuesPerMetric is the maximum number of values (or distinct values of a
+// distribution) that CloudWatch accepts for a single metric in one EMF event.
+const MaxValuesPerMetric = 100
+
+// StatisticSet is a pre-aggregated metric value. CloudWatch accepts it in place of
+// a plain number and records the statistics as if each sample had been sent individually.
+type StatisticSet struct {
+	Max   float64 `json:"Max"`
+	Min   float64 `json:"Min"`
+	Count float64 `json:"Count"`
+	Sum   float64 `json:"Sum"`
+}
+
+// Observe adds a single sample to the statistic set.
+func (s *StatisticSet) Observe(value float64) {
+	s.ObserveN(value, 1)
+}
+
+// ObserveN adds count occurrences of value to the statistic set.
+func (s *StatisticSet) ObserveN(value, count float64) {
+	if count <= 0 {
+		return
+	}
+	if s.Count == 0 || value > s.Max {
+		s.Max = value
+	}
+	if s.Count == 0 || value < s.Min {
+		s.Min = value
+	}
+	s.Count += count
+	s.Sum += value * count
+}
+
+// Merge folds another statistic set into s.
+func (s *StatisticSet) Merge(other StatisticSet) {
+	if other.Count <= 0 {
+		return
+	}
+	if s.Count == 0 || other.Max > s.Max {
+		s.Max = other.Max
+	}
+	if s.Count == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	s.Count += other.Count
+	s.Sum += other.Sum
+}
+
+// Distribution is a pre-aggregated metric value that keeps the individual values
+// together with the number of times each was observed, so that CloudWatch can
+// compute percentiles as well as the basic statistics.
+type Distribution struct {
+	Values []float64 `json:"Values"`
+	Counts []float64 `json:"Counts"`
+	Max    float64   `json:"Max"`
+	Min    float64   `json:"Min"`
+	Count  float64   `json:"Count"`
+	Sum    float64   `json:"Sum"`
+}
+
+// Statistics returns the summary statistics of the distribution.
+func (d Distribution) Statistics() StatisticSet {
+	return StatisticSet{
+		Max:   d.Max,
+		Min:   d.Min,
+		Count: d.Count,
+		Sum:   d.Sum,
+	}
+}
+
+// NewDistribution builds a distribution from a map of values to the number of
+// times each was observed, with the values in ascending order.
+func NewDistribution(counts map[float64]float64) Distribution {
+	dist := Distribution{
+		Values: make([]float64, 0, len(counts)),
+		Counts: make([]float64, 0, len(counts)),
+	}
+	for value := range counts {
+		dist.Values = append(dist.Values, value)
+	}
+	sort.Float64s(dist.Values)
+
+	var stats StatisticSet
+	for _, value := range dist.Values {
+		dist.Counts = append(dist.Counts, counts[value])
+		stats.ObserveN(value, counts[value])
+	}
+	dist.Max, dist.Min, dist.Count, dist.Sum = stats.Max, stats.Min, stats.Count, stats.Sum
+	return dist
+}
+
+// Split splits the distribution into distributions of at most MaxValuesPerMetric
+// values each, which must be emitted in separate metric logs. A distribution
+// within the limit is returned unchanged; the statistics of the parts of a larger
+// one are computed from their values and counts, a missing count counting as 1.
+func (d Distribution) Split() []Distribution {
+	if len(d.Values) <= MaxValuesPerMetric {
+		return []Distribution{d}
+	}
+
+	var parts []Distribution
+	for start := 0; start < len(d.Values); start += MaxValuesPerMetric {
+		end := start + MaxValuesPerMetric
+		if end > len(d.Values) {
+			end = len(d.Values)
+		}
+
+		var stats StatisticSet
+		part := Distribution{}
+		for i := start; i < end; i++ {
+			count := 1.0
+			if i < len(d.Counts) {
+				count = d.Counts[i]
+			}
+			part.Values = append(part.Values, d.Values[i])
+			part.Counts = append(part.Counts, count)
+			stats.ObserveN(d.Values[i], count)
+		}
+		part.Max, part.Min, part.Count, part.Sum = stats.Max, stats.Min, stats.Count, stats.Sum
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// isFinite reports whether f is neither NaN nor infinite.
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// toFloat64 converts a numeric metric value to a float64.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toFloat64Slice converts a metric value that is either a single number or a
+// slice or array of numbers to a slice of float64 values. Non-finite values are
+// skipped. Byte slices are rejected because they serialize as strings.
+func toFloat64Slice(value interface{}) ([]float64, bool) {
+	var values []float64
+	add := func(v interface{}) bool {
+		f, ok := toFloat64(v)
+		if !ok {
+			return false
+		}
+		if isFinite(f) {
+			values = append(values, f)
+		}
+		return true
+	}
+
+	switch v := value.(type) {
+	case []float64:
+		for _, f := range v {
+			add(f)
+		}
+	case []int:
+		for _, i := range v {
+			add(i)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if !add(item) {
+				return nil, false
+			}
+		}
+	default:
+		rv := reflect.ValueOf(value)
+		switch {
+		case rv.Kind() == reflect.Array, rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8:
+			for i := 0; i < rv.Len(); i++ {
+				if !add(rv.Index(i).Interface()) {
+					return nil, false
+				}
+			}
+		case !add(v):
+			return nil, false
+		}
+	}
+
+	return values, true
+}
@@ -0,0 +1,309 @@
+package emf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DimensionSetsProvider is implemented by structs passed to PutStruct that need
+// other dimension sets than the single set of all their dimension fields.
+type DimensionSetsProvider interface {
+	DimensionSets() [][]string
+}
+
+// fieldKind is the role of a tagged struct field.
+type fieldKind int
+
+const (
+	fieldMetric fieldKind = iota
+	fieldDimension
+	fieldProperty
+)
+
+// structField describes a tagged struct field.
+type structField struct {
+	index      []int
+	name       string
+	kind       fieldKind
+	unit       string
+	resolution int
+	omitEmpty  bool
+	// duration is set for metrics of time.Duration values, which are converted to their unit.
+	duration bool
+}
+
+// structFields caches the tagged fields of struct types.
+var structFields sync.Map // map[reflect.Type][]structField
+
+var (
+	durationType     = reflect.TypeOf(time.Duration(0))
+	statisticSetType = reflect.TypeOf(StatisticSet{})
+	distributionType = reflect.TypeOf(Distribution{})
+)
+
+// PutStruct adds the tagged fields of a struct, or pointer to struct, to the log.
+// Fields are tagged with the name of the metric, dimension or property followed by
+// options:
+//
+//	type RequestMetrics struct {
+//		Service   string        `emf:"ServiceName,dimension"`
+//		RequestID string        `emf:"RequestId,property"`
+//		Latency   time.Duration `emf:"Latency,unit=Milliseconds"`
+//		Errors    int           `emf:"Errors,unit=Count,resolution=1,omitempty"`
+//	}
+//
+// Fields tagged without "dimension" or "property" are metrics and must hold a
+// number, a slice of numbers, a StatisticSet or a Distribution. time.Duration
+// metrics are converted to their unit, which defaults to Milliseconds. An empty
+// name defaults to the field name, "-" skips the field, and fields of embedded
+// structs are included. All dimension fields form a single dimension set unless
+// the struct implements DimensionSetsProvider.
+func (ml *MetricLog) PutStruct(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("PutStruct requires a struct or pointer to struct, got %T", v)
+	}
+
+	fields, err := fieldsOf(value.Type())
+	if err != nil {
+		return err
+	}
+
+	var dimensions []string
+	for _, field := range fields {
+		fieldValue, ok := fieldByIndex(value, field.index)
+		if !ok || (field.omitEmpty && fieldValue.IsZero()) {
+			continue
+		}
+		for fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				break
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		if fieldValue.Kind() == reflect.Ptr {
+			continue
+		}
+
+		switch field.kind {
+		case fieldDimension:
+			ml.PutDimension(field.name, fmt.Sprint(fieldValue.Interface()))
+			dimensions = append(dimensions, field.name)
+		case fieldProperty:
+			ml.PutProperty(field.name, fieldValue.Interface())
+		case fieldMetric:
+			metricValue := fieldValue.Interface()
+			if field.duration {
+				metricValue = durationsIn(fieldValue, field.unit)
+			}
+			if field.resolution != 0 {
+				ml.PutMetricWithResolution(field.name, metricValue, field.unit, field.resolution)
+			} else {
+				ml.PutMetric(field.name, metricValue, field.unit)
+			}
+		}
+	}
+
+	if provider, ok := v.(DimensionSetsProvider); ok {
+		for _, dimSet := range provider.DimensionSets() {
+			ml.WithDimensionSet(dimSet)
+		}
+	} else if len(dimensions) > 0 {
+		ml.WithDimensionSet(dimensions)
+	}
+	return nil
+}
+
+// MarshalStruct creates a metric log in the given namespace from the tagged fields
+// of a struct, as PutStruct does, and returns its JSON representation.
+func MarshalStruct(namespace string, v interface{}) ([]byte, error) {
+	ml := NewMetricLog(namespace)
+	if err := ml.PutStruct(v); err != nil {
+		return nil, err
+	}
+	return ml.MarshalJSON()
+}
+
+// fieldsOf returns the tagged fields of a struct type, parsing them on first use.
+func fieldsOf(t reflect.Type) ([]structField, error) {
+	if cached, ok := structFields.Load(t); ok {
+		return cached.([]structField), nil
+	}
+
+	fields, err := parseFields(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	structFields.Store(t, fields)
+	return fields, nil
+}
+
+// parseFields parses the tags of a struct type and its embedded structs.
+func parseFields(t reflect.Type, index []int) ([]structField, error) {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		tag, tagged := f.Tag.Lookup("emf")
+
+		if f.Anonymous && !tagged {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				nested, err := parseFields(embedded, fieldIndex)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, nested...)
+			}
+			continue
+		}
+		if !tagged || tag == "-" || !f.IsExported() {
+			continue
+		}
+
+		field, err := parseTag(f, tag)
+		if err != nil {
+			return nil, err
+		}
+		field.index = fieldIndex
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// parseTag parses the emf tag of a struct field.
+func parseTag(f reflect.StructField, tag string) (structField, error) {
+	parts := strings.Split(tag, ",")
+	field := structField{name: parts[0], kind: fieldMetric}
+	if field.name == "" {
+		field.name = f.Name
+	}
+
+	for _, option := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "dimension":
+			field.kind = fieldDimension
+		case "property":
+			field.kind = fieldProperty
+		case "omitempty":
+			field.omitEmpty = true
+		case "unit":
+			if !unitRegex.MatchString(value) {
+				return field, fmt.Errorf("field %s: invalid unit '%s'", f.Name, value)
+			}
+			field.unit = value
+		case "resolution":
+			resolution, err := strconv.Atoi(value)
+			if err != nil || (resolution != StorageResolutionStandard && resolution != StorageResolutionHigh) {
+				return field, fmt.Errorf("field %s: storage resolution must be %d or %d, got '%s'",
+					f.Name, StorageResolutionStandard, StorageResolutionHigh, value)
+			}
+			field.resolution = resolution
+		default:
+			return field, fmt.Errorf("field %s: unknown emf tag option '%s'", f.Name, option)
+		}
+	}
+
+	if field.kind == fieldMetric {
+		if !isMetricType(f.Type) {
+			return field, fmt.Errorf("field %s: metric must be numeric, a slice of numbers, a StatisticSet or a Distribution, got %s", f.Name, f.Type)
+		}
+		field.duration = isDurationType(f.Type)
+		if field.unit == "" {
+			field.unit = UnitNone
+			if field.duration {
+				field.unit = UnitMilliseconds
+			}
+		}
+		if field.duration && field.unit != UnitSeconds && field.unit != UnitMilliseconds && field.unit != UnitMicroseconds {
+			return field, fmt.Errorf("field %s: duration unit must be Seconds, Milliseconds or Microseconds, got '%s'", f.Name, field.unit)
+		}
+	}
+	return field, nil
+}
+
+// isDurationType reports whether the type is a time.Duration, or a pointer to, slice
+// or array of them.
+func isDurationType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t == durationType
+}
+
+// isMetricType reports whether values of the type can be metric values.
+func isMetricType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == statisticSetType || t == distributionType {
+		return true
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		// Byte slices serialize as strings
+		return false
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// fieldByIndex returns the field with the given index, or false if it is inside a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// durationsIn converts a time.Duration value, or a slice or array of them, to unit.
+func durationsIn(v reflect.Value, unit string) interface{} {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return durationIn(time.Duration(v.Int()), unit)
+	}
+	values := make([]float64, v.Len())
+	for i := range values {
+		values[i] = durationIn(time.Duration(v.Index(i).Int()), unit)
+	}
+	return values
+}
+
+// durationIn converts a duration to a number in the given time unit.
+func durationIn(d time.Duration, unit string) float64 {
+	switch unit {
+	case UnitSeconds:
+		return d.Seconds()
+	case UnitMicroseconds:
+		return float64(d) / float64(time.Microsecond)
+	default:
+		return float64(d) / float64(time.Millisecond)
+	}
+}
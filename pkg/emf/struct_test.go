@@ -0,0 +1,240 @@
+package emf
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type requestInfo struct {
+	Service string `emf:"ServiceName,dimension"`
+	Region  string `emf:",dimension"`
+}
+
+type requestMetrics struct {
+	requestInfo
+	RequestID string        `emf:"RequestId,property"`
+	Latency   time.Duration `emf:"Latency"`
+	Duration  time.Duration `emf:"Duration,unit=Seconds"`
+	Errors    int           `emf:"Errors,unit=Count,resolution=1"`
+	Retries   *int          `emf:"Retries,unit=Count"`
+	Sizes     []float64     `emf:"Sizes,unit=Bytes,omitempty"`
+	Ignored   int           `emf:"-"`
+	Untagged  int
+}
+
+type regionalMetrics struct {
+	Service string `emf:"ServiceName,dimension"`
+	Region  string `emf:"Region,dimension"`
+	Count   int    `emf:"Requests,unit=Count"`
+}
+
+func (regionalMetrics) DimensionSets() [][]string {
+	return [][]string{{"ServiceName"}, {"ServiceName", "Region"}}
+}
+
+func TestPutStruct(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	err := ml.PutStruct(&requestMetrics{
+		requestInfo: requestInfo{Service: "checkout", Region: "eu-west-1"},
+		RequestID:   "abc-123",
+		Latency:     1500 * time.Microsecond,
+		Duration:    2 * time.Second,
+		Errors:      2,
+		Ignored:     1,
+		Untagged:    1,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for key, expected := range map[string]interface{}{
+		"ServiceName": "checkout",
+		"Region":      "eu-west-1",
+		"RequestId":   "abc-123",
+		"Latency":     1.5,
+		"Duration":    2.0,
+		"Errors":      2,
+	} {
+		if value, ok := ml.Value(key); !ok || value != expected {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, value)
+		}
+	}
+	for _, key := range []string{"Retries", "Sizes", "Ignored", "Untagged"} {
+		if _, ok := ml.Value(key); ok {
+			t.Errorf("Expected %s to be skipped", key)
+		}
+	}
+
+	directive := ml.emf.Aws.CloudWatchMetrics[0]
+	if len(directive.Dimensions) != 1 || strings.Join(directive.Dimensions[0], ",") != "ServiceName,Region" {
+		t.Errorf("Expected one dimension set of the dimension fields, got %v", directive.Dimensions)
+	}
+	units := make(map[string]string)
+	for _, metric := range directive.Metrics {
+		units[metric.Name] = *metric.Unit
+		if metric.Name == "Errors" && (metric.StorageResolution == nil || *metric.StorageResolution != StorageResolutionHigh) {
+			t.Error("Expected Errors to be a high resolution metric")
+		}
+	}
+	for name, unit := range map[string]string{"Latency": UnitMilliseconds, "Duration": UnitSeconds, "Errors": UnitCount} {
+		if units[name] != unit {
+			t.Errorf("Expected %s with unit %s, got %q", name, unit, units[name])
+		}
+	}
+	if err := ml.Validate(); err != nil {
+		t.Errorf("Expected a valid metric log, got: %v", err)
+	}
+}
+
+func TestPutStructSliceMetrics(t *testing.T) {
+	type sliceMetrics struct {
+		Service string     `emf:"Service,dimension"`
+		Int64s  []int64    `emf:"Int64s,unit=Count"`
+		Float32 []float32  `emf:"Float32s,unit=Count"`
+		Array   [2]float64 `emf:"Array,unit=Count"`
+		Bytes   []byte     `emf:"Bytes,unit=Bytes"`
+	}
+
+	ml := NewMetricLog("TestNamespace")
+	err := ml.PutStruct(sliceMetrics{Service: "API", Int64s: []int64{1, 2}, Float32: []float32{0.5}, Array: [2]float64{3, 4}})
+	if err == nil {
+		t.Fatal("Expected an error for a byte slice metric")
+	}
+
+	ml = NewMetricLog("TestNamespace")
+	type validMetrics struct {
+		Service string     `emf:"Service,dimension"`
+		Int64s  []int64    `emf:"Int64s,unit=Count"`
+		Float32 []float32  `emf:"Float32s,unit=Count"`
+		Array   [2]float64 `emf:"Array,unit=Count"`
+	}
+	if err := ml.PutStruct(validMetrics{Service: "API", Int64s: []int64{1, 2}, Float32: []float32{0.5}, Array: [2]float64{3, 4}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := ml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	parsed, err := ExtractMetricsJSON(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]StatisticSet{
+		"Int64s":   {Max: 2, Min: 1, Count: 2, Sum: 3},
+		"Float32s": {Max: 0.5, Min: 0.5, Count: 1, Sum: 0.5},
+		"Array":    {Max: 4, Min: 3, Count: 2, Sum: 7},
+	}
+	for name, datums := range map[string][]MetricDatum{"live": ExtractMetrics(ml), "parsed": parsed} {
+		if len(datums) != len(expected) {
+			t.Fatalf("Expected %d %s datums, got %+v", len(expected), name, datums)
+		}
+		for _, datum := range datums {
+			if got := datum.Statistics(); got != expected[datum.MetricName] {
+				t.Errorf("Expected %s statistics %+v for %s, got %+v", name, expected[datum.MetricName], datum.MetricName, got)
+			}
+		}
+	}
+}
+
+func TestPutStructDurations(t *testing.T) {
+	type durationMetrics struct {
+		Service string          `emf:"Service,dimension"`
+		Pointer *time.Duration  `emf:"Pointer"`
+		Slice   []time.Duration `emf:"Slice,unit=Seconds"`
+	}
+
+	timeout := 1500 * time.Millisecond
+	ml := NewMetricLog("TestNamespace")
+	if err := ml.PutStruct(durationMetrics{Service: "API", Pointer: &timeout, Slice: []time.Duration{time.Second, 2 * time.Second}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if value, _ := ml.Value("Pointer"); value != 1500.0 {
+		t.Errorf("Expected 1500 milliseconds, got %v", value)
+	}
+	if unit := *ml.emf.Aws.CloudWatchMetrics[0].Metrics[0].Unit; unit != UnitMilliseconds {
+		t.Errorf("Expected the pointer to default to %s, got %s", UnitMilliseconds, unit)
+	}
+	if value, _ := ml.Value("Slice"); !reflect.DeepEqual(value, []float64{1, 2}) {
+		t.Errorf("Expected the slice in seconds, got %v", value)
+	}
+
+	type invalidUnit struct {
+		Slice []time.Duration `emf:"Slice,unit=Count"`
+	}
+	if err := NewMetricLog("TestNamespace").PutStruct(invalidUnit{}); err == nil {
+		t.Error("Expected an error for a duration slice with a non-time unit")
+	}
+}
+
+func TestPutStructDimensionSets(t *testing.T) {
+	ml := NewMetricLog("TestNamespace")
+	if err := ml.PutStruct(regionalMetrics{Service: "checkout", Region: "eu-west-1", Count: 3}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dimensions := ml.emf.Aws.CloudWatchMetrics[0].Dimensions
+	if len(dimensions) != 2 || len(dimensions[0]) != 1 || len(dimensions[1]) != 2 {
+		t.Errorf("Expected the dimension sets of the struct, got %v", dimensions)
+	}
+}
+
+func TestPutStructErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{"not a struct", 42, "requires a struct"},
+		{"invalid unit", struct {
+			Latency int `emf:"Latency,unit=Hours"`
+		}{}, "invalid unit"},
+		{"invalid resolution", struct {
+			Latency int `emf:"Latency,resolution=10"`
+		}{}, "storage resolution"},
+		{"unknown option", struct {
+			Latency int `emf:"Latency,sum"`
+		}{}, "unknown emf tag option"},
+		{"non-numeric metric", struct {
+			Name string `emf:"Name"`
+		}{}, "metric must be numeric"},
+		{"duration unit", struct {
+			Latency time.Duration `emf:"Latency,unit=Bytes"`
+		}{}, "duration unit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewMetricLog("TestNamespace").PutStruct(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestMarshalStruct(t *testing.T) {
+	data, err := MarshalStruct("TestNamespace", regionalMetrics{Service: "checkout", Region: "eu-west-1", Count: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if event["Requests"] != 3.0 || event["ServiceName"] != "checkout" {
+		t.Errorf("Expected the struct fields in the output, got %s", data)
+	}
+
+	if _, err := MarshalStruct("TestNamespace", struct {
+		Count int `emf:"Requests"`
+	}{Count: 1}); err == nil {
+		t.Error("Expected an error for a struct without dimensions")
+	}
+}
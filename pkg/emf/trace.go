@@ -0,0 +1,176 @@
+package emf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Trace header names and properties
+const (
+	// XRayTraceEnv is the environment variable in which AWS Lambda passes the X-Ray
+	// trace header of the current invocation.
+	XRayTraceEnv = "_X_AMZN_TRACE_ID"
+	// LambdaFunctionEnv is the environment variable that names the AWS Lambda
+	// function. XRayTraceEnv is only used when it is set.
+	LambdaFunctionEnv = "AWS_LAMBDA_FUNCTION_NAME"
+	// PropertyXRayTraceID is the property that holds the trace ID in X-Ray format,
+	// which CloudWatch uses to link log events to X-Ray traces.
+	PropertyXRayTraceID = "xray_trace_id"
+)
+
+// lambdaTraceKey is the context key under which the AWS Lambda Go runtime
+// (github.com/aws/aws-lambda-go) stores the X-Ray trace header of an invocation.
+// It mirrors the runtime's key, which is a plain string, so it cannot be given a
+// type of its own without no longer matching.
+const lambdaTraceKey = "x-amzn-trace-id"
+
+// traceHeaderKey is the context key of ContextWithTraceHeader.
+type traceHeaderKey struct{}
+
+// TraceFormat is the format of a trace header.
+type TraceFormat int
+
+const (
+	// TraceFormatXRay is the X-Ray trace header, e.g.
+	// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1.
+	TraceFormatXRay TraceFormat = iota
+	// TraceFormatW3C is the W3C traceparent header, e.g.
+	// 00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01.
+	TraceFormatW3C
+)
+
+// Trace identifies the trace of a request.
+type Trace struct {
+	// TraceID is the trace ID in the format of its header.
+	TraceID string
+	// ParentID is the ID of the parent segment or span. It may be empty.
+	ParentID string
+	Sampled  bool
+	Format   TraceFormat
+}
+
+// XRayTraceID returns the trace ID in X-Ray format. W3C trace IDs are converted,
+// taking the first 8 hex digits as the epoch; it returns "" if the W3C trace ID
+// is not 32 lowercase hex digits.
+func (t Trace) XRayTraceID() string {
+	if t.Format == TraceFormatXRay {
+		return t.TraceID
+	}
+	if !isHex(t.TraceID, 32) {
+		return ""
+	}
+	return "1-" + t.TraceID[:8] + "-" + t.TraceID[8:]
+}
+
+// ParseXRayTraceHeader parses an X-Ray trace header.
+func ParseXRayTraceHeader(header string) (Trace, error) {
+	trace := Trace{Format: TraceFormatXRay}
+	for _, field := range strings.Split(header, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "Root":
+			trace.TraceID = value
+		case "Parent":
+			trace.ParentID = value
+		case "Sampled":
+			trace.Sampled = value == "1"
+		}
+	}
+
+	parts := strings.Split(trace.TraceID, "-")
+	if len(parts) != 3 || parts[0] != "1" || !isHex(parts[1], 8) || !isHex(parts[2], 24) {
+		return Trace{}, fmt.Errorf("invalid X-Ray trace header '%s'", header)
+	}
+	return trace, nil
+}
+
+// ParseTraceparent parses a W3C traceparent header.
+func ParseTraceparent(header string) (Trace, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" || !isHex(parts[1], 32) ||
+		!isHex(parts[2], 16) || !isHex(parts[3], 2) || (parts[0] == "00" && len(parts) != 4) ||
+		strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return Trace{}, fmt.Errorf("invalid traceparent header '%s'", header)
+	}
+
+	return Trace{
+		TraceID:  parts[1],
+		ParentID: parts[2],
+		Sampled:  parts[3][1]&1 == 1,
+		Format:   TraceFormatW3C,
+	}, nil
+}
+
+// ParseTraceHeader parses an X-Ray trace header or a W3C traceparent header.
+func ParseTraceHeader(header string) (Trace, error) {
+	if strings.Contains(header, "Root=") {
+		return ParseXRayTraceHeader(header)
+	}
+	return ParseTraceparent(header)
+}
+
+// isHex reports whether s consists of n lowercase hex digits.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithTraceHeader returns a copy of ctx that carries an X-Ray trace header or
+// a W3C traceparent header, for example from an incoming HTTP request.
+func ContextWithTraceHeader(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, traceHeaderKey{}, header)
+}
+
+// TraceFromContext returns the trace of ctx. It uses the header stored by
+// ContextWithTraceHeader, then the X-Ray trace header stored by the AWS Lambda Go
+// runtime, then, in AWS Lambda only, the _X_AMZN_TRACE_ID environment variable.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	var headers []string
+	if ctx != nil {
+		header, _ := ctx.Value(traceHeaderKey{}).(string)
+		lambdaHeader, _ := ctx.Value(lambdaTraceKey).(string)
+		headers = append(headers, header, lambdaHeader)
+	}
+	// Outside Lambda the variable is process-wide and would tag unrelated requests
+	if os.Getenv(LambdaFunctionEnv) != "" {
+		headers = append(headers, os.Getenv(XRayTraceEnv))
+	}
+
+	for _, header := range headers {
+		if header == "" {
+			continue
+		}
+		if trace, err := ParseTraceHeader(header); err == nil {
+			return trace, true
+		}
+	}
+	return Trace{}, false
+}
+
+// PutTraceContext adds the trace ID of ctx as the TraceId property, in the format of
+// its header. The log is unchanged if ctx carries no trace.
+func (ml *MetricLog) PutTraceContext(ctx context.Context) *MetricLog {
+	if trace, found := TraceFromContext(ctx); found {
+		ml.PutTraceID(trace.TraceID)
+	}
+	return ml
+}
+
+// PutXRayTraceContext adds the trace ID of ctx in X-Ray format as the xray_trace_id
+// property, so that CloudWatch can link the log event to the trace. The log is
+// unchanged if ctx carries no trace.
+func (ml *MetricLog) PutXRayTraceContext(ctx context.Context) *MetricLog {
+	if trace, found := TraceFromContext(ctx); found && trace.XRayTraceID() != "" {
+		ml.PutProperty(PropertyXRayTraceID, trace.XRayTraceID())
+	}
+	return ml
+}
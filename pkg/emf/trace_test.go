@@ -0,0 +1,134 @@
+package emf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+const (
+	testXRayHeader  = "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+	testTraceparent = "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"
+)
+
+func TestParseTraceHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected Trace
+		wantErr  bool
+	}{
+		{
+			name:     "X-Ray",
+			header:   testXRayHeader,
+			expected: Trace{TraceID: "1-5759e988-bd862e3fe1be46a994272793", ParentID: "53995c3f42cd8ad8", Sampled: true, Format: TraceFormatXRay},
+		},
+		{
+			name:     "X-Ray without parent",
+			header:   "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=0",
+			expected: Trace{TraceID: "1-5759e988-bd862e3fe1be46a994272793", Format: TraceFormatXRay},
+		},
+		{
+			name:     "traceparent",
+			header:   testTraceparent,
+			expected: Trace{TraceID: "5759e988bd862e3fe1be46a994272793", ParentID: "53995c3f42cd8ad8", Sampled: true, Format: TraceFormatW3C},
+		},
+		{name: "invalid X-Ray root", header: "Root=1-5759e988;Sampled=1", wantErr: true},
+		{name: "invalid traceparent", header: "00-5759e988-53995c3f42cd8ad8-01", wantErr: true},
+		{name: "zero trace ID", header: "00-00000000000000000000000000000000-53995c3f42cd8ad8-01", wantErr: true},
+		{name: "empty", header: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, err := ParseTraceHeader(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", trace)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if trace != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, trace)
+			}
+			if trace.XRayTraceID() != "1-5759e988-bd862e3fe1be46a994272793" {
+				t.Errorf("Expected the X-Ray trace ID, got %s", trace.XRayTraceID())
+			}
+		})
+	}
+}
+
+func TestXRayTraceIDInvalid(t *testing.T) {
+	for _, traceID := range []string{"", "abc", "5759E988BD862E3FE1BE46A994272793"} {
+		if id := (Trace{TraceID: traceID, Format: TraceFormatW3C}).XRayTraceID(); id != "" {
+			t.Errorf("Expected no X-Ray trace ID for %q, got %s", traceID, id)
+		}
+	}
+}
+
+func TestTraceFromContext(t *testing.T) {
+	t.Setenv(XRayTraceEnv, "")
+	if _, found := TraceFromContext(context.Background()); found {
+		t.Error("Expected no trace in an empty context")
+	}
+
+	t.Setenv(XRayTraceEnv, testXRayHeader)
+	t.Setenv(LambdaFunctionEnv, "")
+	if _, found := TraceFromContext(context.Background()); found {
+		t.Errorf("Expected %s to be ignored outside AWS Lambda", XRayTraceEnv)
+	}
+
+	t.Setenv(LambdaFunctionEnv, "checkout")
+	if trace, found := TraceFromContext(context.Background()); !found || trace.Format != TraceFormatXRay {
+		t.Errorf("Expected the trace from %s, got %+v", XRayTraceEnv, trace)
+	}
+
+	// The Lambda runtime stores the header under a plain string key
+	ctx := context.WithValue(context.Background(), lambdaTraceKey, "Root=1-5759e988-00000000000000000000abcd") //nolint:staticcheck // SA1029: must match the Lambda runtime's key
+	if trace, _ := TraceFromContext(ctx); trace.TraceID != "1-5759e988-00000000000000000000abcd" {
+		t.Errorf("Expected the trace from the Lambda context, got %+v", trace)
+	}
+
+	ctx = ContextWithTraceHeader(ctx, testTraceparent)
+	if trace, _ := TraceFromContext(ctx); trace.Format != TraceFormatW3C {
+		t.Errorf("Expected the trace from ContextWithTraceHeader, got %+v", trace)
+	}
+}
+
+func TestLoggerTraceCorrelation(t *testing.T) {
+	t.Setenv(XRayTraceEnv, "")
+	ctx := ContextWithTraceHeader(context.Background(), testTraceparent)
+
+	var buf bytes.Buffer
+	logger := NewLogger(LoggerConfig{
+		Namespace:         "TestNamespace",
+		DefaultDimensions: []Dimension{{Name: "Service", Value: "API"}},
+		Sink:              NewWriterSink(&buf),
+		XRayTraceID:       true,
+	})
+	ml := logger.NewMetricLogContext(ctx)
+	ml.PutMetric("Latency", 42.0, UnitMilliseconds)
+	if err := logger.Emit(ml); err != nil {
+		t.Fatalf("Failed to emit: %v", err)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("Failed to unmarshal the log: %v", err)
+	}
+	if event[PropertyTraceID] != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("Expected the W3C trace ID, got %v", event[PropertyTraceID])
+	}
+	if event[PropertyXRayTraceID] != "1-5759e988-bd862e3fe1be46a994272793" {
+		t.Errorf("Expected the X-Ray trace ID, got %v", event[PropertyXRayTraceID])
+	}
+
+	ml = NewMetricLog("TestNamespace").Builder().TraceContext(context.Background()).Build()
+	if _, exists := ml.Value(PropertyTraceID); exists {
+		t.Error("Expected no TraceId without a trace")
+	}
+}
@@ -3,6 +3,7 @@ package emf
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // Pre-compiled regular expressions for validation
@@ -10,10 +11,31 @@ var (
 	unitRegex = regexp.MustCompile(`^(Seconds|Microseconds|Milliseconds|Bytes|Kilobytes|Megabytes|Gigabytes|Terabytes|Bits|Kilobits|Megabits|Gigabits|Terabits|Percent|Count|Bytes\/Second|Kilobytes\/Second|Megabytes\/Second|Gigabytes\/Second|Terabytes\/Second|Bits\/Second|Kilobits\/Second|Megabits\/Second|Gigabits\/Second|Terabits\/Second|Count\/Second|None)$`)
 )
 
+// IsValidUnit reports whether unit is one of the units CloudWatch accepts.
+func IsValidUnit(unit string) bool {
+	return unitRegex.MatchString(unit)
+}
+
 // Validate performs validation on the metric log to ensure it conforms to the EMF spec.
 func (ml *MetricLog) Validate() error {
+	// Validate the log as declared, with the default dimensions applied. Duplicate
+	// dimension sets and metric definitions are only removed when serializing.
+	ml = ml.withDefaults()
+
+	// Validate each metric directive; logs built in code have exactly one,
+	// parsed logs may have several
+	for _, directive := range ml.emf.Aws.CloudWatchMetrics {
+		if err := ml.validateDirective(directive); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateDirective validates a single metric directive against the values of the log.
+func (ml *MetricLog) validateDirective(directive EmfFormatJsonAwsCloudWatchMetricsElem) error {
 	// Validate namespace
-	directive := ml.emf.Aws.CloudWatchMetrics[0]
 	if len(directive.Namespace) < MinNamespaceLength {
 		return fmt.Errorf("namespace length must be at least %d characters", MinNamespaceLength)
 	}
@@ -38,54 +60,133 @@ func (ml *MetricLog) Validate() error {
 		}
 	}
 
-	// Validate metric names
+	// Validate metric definitions
 	for _, metric := range directive.Metrics {
-		if len(metric.Name) < MinMetricNameLength {
-			return fmt.Errorf("metric name '%s' length must be at least %d characters", metric.Name, MinMetricNameLength)
-		}
-		if len(metric.Name) > MaxMetricNameLength {
-			return fmt.Errorf("metric name '%s' length must be at most %d characters", metric.Name, MaxMetricNameLength)
-		}
-
-		// Validate that we have a metric value
-		if _, exists := ml.metrics[metric.Name]; !exists {
-			return fmt.Errorf("metric '%s' is defined but no value is provided", metric.Name)
-		}
-
-		// Validate unit if provided
-		if metric.Unit != nil {
-			if !unitRegex.MatchString(*metric.Unit) {
-				return fmt.Errorf("invalid unit '%s' for metric '%s'", *metric.Unit, metric.Name)
-			}
-		}
-
-		// Validate storage resolution if provided
-		if metric.StorageResolution != nil {
-			if *metric.StorageResolution != StorageResolutionStandard && *metric.StorageResolution != StorageResolutionHigh {
-				return fmt.Errorf("invalid storage resolution for metric '%s'. Must be either %d (standard) or %d (high resolution)",
-					metric.Name, StorageResolutionStandard, StorageResolutionHigh)
-			}
+		if err := ml.validateMetric(metric); err != nil {
+			return err
 		}
 	}
 
 	// Validate dimension sets
 	for i, dimSet := range directive.Dimensions {
-		if len(dimSet) > MaxDimensionSetSize {
-			return fmt.Errorf("dimension set %d exceeds maximum size of %d", i, MaxDimensionSetSize)
+		if err := ml.validateDimensionSetSize(dimSet); err != nil {
+			return fmt.Errorf("dimension set %d %v", i, err)
 		}
 
 		// Validate each dimension in the set
+		seen := make(map[string]bool, len(dimSet))
 		for _, dim := range dimSet {
-			if len(dim) > MaxDimensionNameLength {
-				return fmt.Errorf("dimension name '%s' exceeds maximum length of %d", dim, MaxDimensionNameLength)
+			if seen[dim] {
+				return fmt.Errorf("dimension set %d contains dimension '%s' more than once", i, dim)
 			}
+			seen[dim] = true
 
-			// Ensure the dimension has a value
-			if _, exists := ml.metrics[dim]; !exists {
-				return fmt.Errorf("dimension '%s' is referenced but no value is provided", dim)
+			if err := ml.validateDimension(dim); err != nil {
+				return err
 			}
 		}
 	}
 
 	return nil
 }
+
+// validateMetric validates a metric definition against the values of the log.
+func (ml *MetricLog) validateMetric(metric EmfFormatJsonAwsCloudWatchMetricsElemMetricsElem) error {
+	if len(metric.Name) < MinMetricNameLength {
+		return fmt.Errorf("metric name '%s' length must be at least %d characters", metric.Name, MinMetricNameLength)
+	}
+	if len(metric.Name) > MaxMetricNameLength {
+		return fmt.Errorf("metric name '%s' length must be at most %d characters", metric.Name, MaxMetricNameLength)
+	}
+
+	// Validate that we have a metric value
+	if _, exists := ml.metrics[metric.Name]; !exists {
+		return fmt.Errorf("metric '%s' is defined but no value is provided", metric.Name)
+	}
+
+	// Validate unit if provided
+	if metric.Unit != nil {
+		if !unitRegex.MatchString(*metric.Unit) {
+			return fmt.Errorf("invalid unit '%s' for metric '%s'", *metric.Unit, metric.Name)
+		}
+	}
+
+	// Validate storage resolution if provided
+	if metric.StorageResolution != nil {
+		if *metric.StorageResolution != StorageResolutionStandard && *metric.StorageResolution != StorageResolutionHigh {
+			return fmt.Errorf("invalid storage resolution for metric '%s'. Must be either %d (standard) or %d (high resolution)",
+				metric.Name, StorageResolutionStandard, StorageResolutionHigh)
+		}
+	}
+
+	return nil
+}
+
+// validateDimensionSetSize checks that a dimension set, with the default dimensions
+// applied, does not exceed MaxDimensionSetSize.
+func (ml *MetricLog) validateDimensionSetSize(dimSet []string) error {
+	if len(dimSet) <= MaxDimensionSetSize {
+		return nil
+	}
+	if len(ml.defaultDimensions) > 0 && !ml.replaceDefaults {
+		return fmt.Errorf("has %d dimensions including %d default dimensions, exceeding the maximum of %d",
+			len(dimSet), len(ml.defaultDimensions), MaxDimensionSetSize)
+	}
+	return fmt.Errorf("exceeds maximum size of %d", MaxDimensionSetSize)
+}
+
+// validateDimension validates a dimension referenced by a dimension set against the values of the log.
+// Dimension names and values must be non-empty printable ASCII within their length limits,
+// names must not start with a colon and values must be strings.
+func (ml *MetricLog) validateDimension(dim string) error {
+	if dim == "" {
+		return fmt.Errorf("dimension name must not be empty")
+	}
+	if len(dim) > MaxDimensionNameLength {
+		return fmt.Errorf("dimension name '%s' exceeds maximum length of %d", dim, MaxDimensionNameLength)
+	}
+	if !isASCII(dim) {
+		return fmt.Errorf("dimension name '%s' must contain only printable ASCII characters", dim)
+	}
+	if strings.HasPrefix(dim, ":") {
+		return fmt.Errorf("dimension name '%s' must not start with a colon", dim)
+	}
+
+	// Ensure the dimension has a value
+	value, exists := ml.metrics[dim]
+	if !exists {
+		return fmt.Errorf("dimension '%s' is referenced but no value is provided", dim)
+	}
+
+	// Ensure the value is a valid dimension value
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("dimension '%s' must have a string value, got %T", dim, value)
+	}
+	if strings.TrimSpace(str) == "" {
+		return fmt.Errorf("dimension '%s' must have a non-empty value", dim)
+	}
+	if len(str) > MaxDimensionValueLength {
+		return fmt.Errorf("value of dimension '%s' exceeds maximum length of %d", dim, MaxDimensionValueLength)
+	}
+	if !isASCII(str) {
+		return fmt.Errorf("value of dimension '%s' must contain only printable ASCII characters", dim)
+	}
+
+	return nil
+}
+
+// isASCII reports whether s contains only printable ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isPrintableASCII(rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPrintableASCII reports whether r is a printable ASCII character.
+func isPrintableASCII(r rune) bool {
+	return r >= 0x20 && r <= 0x7e
+}